@@ -0,0 +1,68 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+)
+
+// monoPalette dithers a terminal preview image down to black/white before
+// it's rendered as braille glyphs - matching the 2-color contrast braille
+// characters can actually represent.
+var monoPalette = color.Palette{color.Black, color.White}
+
+// printBraille renders img to w as rows of Unicode braille characters, each
+// glyph packing a 2x4 block of dithered pixels into its 8 dots.
+//
+// This replaces gotpi's former dependency on
+// github.com/kevin-cantwell/dotmatrix: that package's image.go/braille.go
+// are the only parts gotpi ever used (via termPrint), but its mp4.go and
+// webcam.go files unconditionally import github.com/asticode/go-astiav, a
+// cgo binding to FFmpeg, which made the whole module unbuildable without
+// libavcodec/libavformat system libraries even though gotpi never touches
+// that video-capture code.
+func printBraille(w io.Writer, img image.Image) error {
+	dithered := image.NewPaletted(img.Bounds(), monoPalette)
+	draw.FloydSteinberg.Draw(dithered, dithered.Bounds(), img, img.Bounds().Min)
+
+	bounds := dithered.Bounds()
+	for py := bounds.Min.Y; py < bounds.Max.Y; py += 4 {
+		for px := bounds.Min.X; px < bounds.Max.X; px += 2 {
+			if _, err := w.Write([]byte(string(brailleRune(dithered, px, py)))); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write([]byte{'\n'}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// brailleDots lists the (x,y) offset within a glyph's 2x4 block for each
+// bit of the braille dot pattern, in the Unicode Braille Patterns block's
+// bit order: dots 1-2-3 down the left column, 4-5-6 down the right, then
+// 7-8 on the bottom row.
+var brailleDots = [8][2]int{
+	{0, 0}, {0, 1}, {0, 2},
+	{1, 0}, {1, 1}, {1, 2},
+	{0, 3}, {1, 3},
+}
+
+// brailleRune builds the braille character for the 2x4 block of img at
+// (px, py), treating any pixel outside img's bounds as unset.
+func brailleRune(img *image.Paletted, px, py int) rune {
+	var dots int
+	bounds := img.Bounds()
+	for i, off := range brailleDots {
+		x, y := px+off[0], py+off[1]
+		if x >= bounds.Max.X || y >= bounds.Max.Y {
+			continue
+		}
+		if img.At(x, y) == color.Black {
+			dots |= 1 << uint(i)
+		}
+	}
+	return rune(dots) + '⠀'
+}
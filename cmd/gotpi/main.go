@@ -1,16 +1,18 @@
 package main
 
 import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"image"
-	"image/png"
-	_ "image/png"
 	"os"
+	"strconv"
 
 	"github.com/akamensky/argparse"
-	"github.com/kevin-cantwell/dotmatrix"
+	"github.com/disintegration/imaging"
 	"github.com/micheledinelli/gotpi"
-	"github.com/nfnt/resize"
+	"github.com/micheledinelli/gotpi/qrtransport"
 )
 
 func main() {
@@ -18,24 +20,45 @@ func main() {
 	keyGen := cli.NewCommand("key-gen", "Generate a new OTP key image")
 	keyF := keyGen.String("o", "out", &argparse.Options{Required: false, Help: "Path to store the generated otp key", Default: "otp-key.png"})
 	keyW := keyGen.Int("w", "width", &argparse.Options{Required: false, Help: "Width (same as height) of the generated otp key image", Default: 256})
+	keyPassphrase := keyGen.String("", "passphrase", &argparse.Options{Required: false, Help: "Derive the key deterministically from this passphrase instead of crypto/rand; trades perfect OTP secrecy for memorability (see gotpi.KeyGenFromPassphrase)"})
+
+	keyRegen := cli.NewCommand("key-regen", "Reproduce a passphrase-derived OTP key image from its salt")
+	keyRegenPassphrase := keyRegen.String("", "passphrase", &argparse.Options{Required: true, Help: "Passphrase the key was originally derived from"})
+	keyRegenFrom := keyRegen.String("", "from", &argparse.Options{Required: true, Help: "Path to the existing key image carrying the gotpi-salt chunk"})
+	keyRegenOut := keyRegen.String("o", "out", &argparse.Options{Required: false, Help: "Path to store the regenerated otp key", Default: "otp-key.png"})
 
 	enc := cli.NewCommand("enc", "Encrypt an image using an OTP key image")
 	imgF := enc.String("f", "file", &argparse.Options{Required: true, Help: "Path of the image to encrypt"})
 	key := enc.String("k", "key", &argparse.Options{Required: true, Help: "Path of the key image to use for encryption"})
 	outEnc := enc.String("o", "out", &argparse.Options{Required: false, Help: "Path to save the encrypted image", Default: "enc.png"})
+	fmtEnc := enc.String("", "format", &argparse.Options{Required: false, Help: "Output format: png, jpeg, gif, tiff, bmp, webp (default: inferred from --out extension)"})
+	stream := enc.Flag("", "stream", &argparse.Options{Required: false, Help: "Encrypt row-band by row-band (default tile size 256 rows) instead of loading the whole image, so file and key can be far larger than RAM; requires both to be 8-bit, non-interlaced PNG, and implies --legacy and ignores --format (output is always PNG)", Default: false})
 
 	dec := cli.NewCommand("dec", "Decrypt an image using an OTP key image")
 	decImgF := dec.String("f", "file", &argparse.Options{Required: true, Help: "Path of the image to decrypt"})
 	decKey := dec.String("k", "key", &argparse.Options{Required: true, Help: "Path of the key image to use for decryption"})
 	outDec := dec.String("o", "out", &argparse.Options{Required: false, Help: "Path to save the decrypted image", Default: "dec.png"})
+	fmtDec := dec.String("", "format", &argparse.Options{Required: false, Help: "Output format: png, jpeg, gif, tiff, bmp, webp (default: inferred from --out extension)"})
 
 	xor := cli.NewCommand("xor", "XOR two images together")
 	xorImg1 := xor.String("a", "img1", &argparse.Options{Required: true, Help: "Path of the first image"})
 	xorImg2 := xor.String("b", "img2", &argparse.Options{Required: true, Help: "Path of the second image"})
 	outXor := xor.String("o", "out", &argparse.Options{Required: false, Help: "Path to save the XORed image", Default: "xor.png"})
+	fmtXor := xor.String("", "format", &argparse.Options{Required: false, Help: "Output format: png, jpeg, gif, tiff, bmp, webp (default: inferred from --out extension)"})
+
+	keyExport := cli.NewCommand("key-export", "Export an OTP key image as a QR key sheet (digital transport only, see key-import)")
+	keyExpIn := keyExport.String("", "in", &argparse.Options{Required: true, Help: "Path to the OTP key image to export"})
+	keyExpOut := keyExport.String("", "out", &argparse.Options{Required: false, Help: "Path to save the QR key sheet", Default: "key-sheet.png"})
+	keyExpECC := keyExport.String("", "ecc", &argparse.Options{Required: false, Help: "QR error-correction level: L, M, Q or H", Default: "M"})
+	keyExpVersion := keyExport.Int("", "version", &argparse.Options{Required: false, Help: "QR code version (1-5 at L, 1-3 at M, 1-2 at Q/H)", Default: 3})
+
+	keyImport := cli.NewCommand("key-import", "Reconstruct an OTP key image from a QR key sheet produced by key-export")
+	keyImpIn := keyImport.String("", "in", &argparse.Options{Required: true, Help: "Path to the key sheet PNG (tolerates re-saving or resizing the file key-export wrote, but not a print-and-scan or photograph round trip - see ImportKeySheet)"})
+	keyImpOut := keyImport.String("", "out", &argparse.Options{Required: false, Help: "Path to save the reconstructed OTP key image", Default: "otp-key.png"})
 
 	verbose := cli.Flag("v", "verbose", &argparse.Options{Required: false, Help: "Print the encrypted image to terminal", Default: false})
 	rgb := cli.Flag("c", "rgb", &argparse.Options{Required: false, Help: "use RGB mode instead of black and white", Default: false})
+	legacy := cli.Flag("", "legacy", &argparse.Options{Required: false, Help: "Use the unauthenticated raw ciphertext format instead of the gtPi container", Default: false})
 
 	err := cli.Parse(os.Args)
 	if err != nil {
@@ -44,20 +67,109 @@ func main() {
 	}
 
 	if keyGen.Happened() {
-		k := gotpi.KeyGen(keyF, keyW, *rgb)
-		save(*keyF, k)
+		if *keyPassphrase != "" {
+			salt := make([]byte, 16)
+			if _, err := rand.Read(salt); err != nil {
+				panic(err)
+			}
+			k := gotpi.KeyGenFromPassphrase([]byte(*keyPassphrase), salt, *keyW, *rgb)
+			saveWithSalt(*keyF, k, salt)
+			if *verbose {
+				termPrint(k)
+				fmt.Printf("otp key written to %s (regenerate with key-regen --passphrase ... --from %s)\n", *keyF, *keyF)
+			}
+		} else {
+			k := gotpi.KeyGen(*keyW, *rgb)
+			save(*keyF, k)
+			if *verbose {
+				termPrint(k)
+				fmt.Printf("otp key written to %s\n", *keyF)
+			}
+		}
+	}
+
+	if keyRegen.Happened() {
+		raw, err := os.ReadFile(*keyRegenFrom)
+		if err != nil {
+			panic(err)
+		}
+		saltHex, ok, err := gotpi.ReadPNGText(raw, "gotpi-salt")
+		if err != nil {
+			panic(err)
+		}
+		if !ok {
+			panic("gotpi: " + *keyRegenFrom + " has no gotpi-salt chunk; it wasn't generated with key-gen --passphrase")
+		}
+		salt, err := hex.DecodeString(saltHex)
+		if err != nil {
+			panic(err)
+		}
+		keyImg, _, err := gotpi.Load(bytes.NewReader(raw))
+		if err != nil {
+			panic(err)
+		}
+		bounds := keyImg.Bounds()
+
+		k := gotpi.KeyGenFromPassphrase([]byte(*keyRegenPassphrase), salt, bounds.Dx(), *rgb)
+		saveWithSalt(*keyRegenOut, k, salt)
+
 		if *verbose {
 			termPrint(k)
-			fmt.Printf("otp key written to %s\n", *keyF)
+			fmt.Printf("otp key regenerated to %s\n", *keyRegenOut)
 		}
 	}
 
-	if enc.Happened() {
-		img := imgOpen(*imgF)
-		keyImg := imgOpen(*key)
+	if enc.Happened() && *stream {
+		srcF, err := os.Open(*imgF)
+		if err != nil {
+			panic(err)
+		}
+		defer srcF.Close()
+		keyF, err := os.Open(*key)
+		if err != nil {
+			panic(err)
+		}
+		defer keyF.Close()
+		outF, err := os.Create(*outEnc)
+		if err != nil {
+			panic(err)
+		}
+		defer outF.Close()
+
+		if err := gotpi.EncryptStream(srcF, keyF, outF, gotpi.StreamOpts{}); err != nil {
+			panic(err)
+		}
+		if *verbose {
+			fmt.Printf("streamed %s into %s\n", *imgF, *outEnc)
+		}
+	} else if enc.Happened() {
+		srcF, err := os.Open(*imgF)
+		if err != nil {
+			panic(err)
+		}
+		img, orientation, err := gotpi.NormalizeForEncrypt(srcF)
+		srcF.Close()
+		if err != nil {
+			panic(err)
+		}
+		keyRaw, err := os.ReadFile(*key)
+		if err != nil {
+			panic(err)
+		}
+		keyImg, _, err := gotpi.Load(bytes.NewReader(keyRaw))
+		if err != nil {
+			panic(err)
+		}
 
-		out := gotpi.Encrypt(img, keyImg, *rgb)
-		save(*outEnc, out)
+		var out image.Image
+		if *legacy {
+			out = gotpi.Encrypt(img, keyImg, *rgb)
+			saveOrientedAs(*outEnc, out, *fmtEnc, orientation)
+		} else {
+			var c *gotpi.Container
+			out, c = gotpi.EncryptToContainer(img, keyImg, keyRaw, *rgb)
+			saveContainerAs(*outEnc, out, c, orientation, *fmtEnc)
+		}
 
 		if verbose != nil && *verbose {
 			fmt.Printf("encrypting %s", *imgF)
@@ -70,11 +182,44 @@ func main() {
 	}
 
 	if dec.Happened() {
-		decImg := imgOpen(*decImgF)
-		decKeyImg := imgOpen(*decKey)
+		raw, err := os.ReadFile(*decImgF)
+		if err != nil {
+			panic(err)
+		}
+		decImg, _, err := gotpi.Load(bytes.NewReader(raw))
+		if err != nil {
+			panic(err)
+		}
+		keyRaw, err := os.ReadFile(*decKey)
+		if err != nil {
+			panic(err)
+		}
+		decKeyImg, _, err := gotpi.Load(bytes.NewReader(keyRaw))
+		if err != nil {
+			panic(err)
+		}
 
-		out := gotpi.Decrypt(decImg, decKeyImg, *rgb)
-		save(*outDec, out)
+		var out image.Image
+		if *legacy {
+			out = gotpi.Decrypt(decImg, decKeyImg, *rgb)
+		} else {
+			c, err := gotpi.ExtractContainer(raw)
+			if err != nil {
+				panic(err)
+			}
+			out, err = gotpi.DecryptContainer(decImg, decKeyImg, keyRaw, c)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+
+		if v, ok, _ := gotpi.ReadPNGText(raw, "gotpi-orientation"); ok {
+			if o, err := strconv.Atoi(v); err == nil {
+				out = gotpi.RestoreOrientation(out, o)
+			}
+		}
+		saveAs(*outDec, out, *fmtDec)
 
 		if verbose != nil && *verbose {
 			fmt.Printf("decrypting %s", *decImgF)
@@ -87,11 +232,11 @@ func main() {
 	}
 
 	if xor.Happened() {
-		a := imgOpen(*xorImg1)
-		b := imgOpen(*xorImg2)
+		a, _ := imgOpen(*xorImg1)
+		b, _ := imgOpen(*xorImg2)
 
 		out := gotpi.Encrypt(a, b, *rgb)
-		save(*outXor, out)
+		saveAs(*outXor, out, *fmtXor)
 
 		if verbose != nil && *verbose {
 			fmt.Printf("XORing %s with %s", *xorImg1, *xorImg2)
@@ -101,13 +246,76 @@ func main() {
 			termPrint(out)
 		}
 	}
+
+	if keyExport.Happened() {
+		keyImg, _ := imgOpen(*keyExpIn)
+		ecc, err := qrtransport.ParseECCLevel(*keyExpECC)
+		if err != nil {
+			panic(err)
+		}
+
+		sheet, manifest, err := gotpi.ExportKeySheet(keyImg, *keyExpVersion, ecc)
+		if err != nil {
+			panic(err)
+		}
+
+		var buf bytes.Buffer
+		if err := gotpi.Save(&buf, sheet, gotpi.FormatPNG, nil); err != nil {
+			panic(err)
+		}
+		out := buf.Bytes()
+		for k, v := range manifest.Fields() {
+			out, err = gotpi.InsertPNGText(out, k, v)
+			if err != nil {
+				panic(err)
+			}
+		}
+		if err := os.WriteFile(*keyExpOut, out, 0644); err != nil {
+			panic(err)
+		}
+
+		if *verbose {
+			fmt.Printf("key sheet written to %s (%d QR tiles)\n", *keyExpOut, manifest.Tiles)
+		}
+	}
+
+	if keyImport.Happened() {
+		raw, err := os.ReadFile(*keyImpIn)
+		if err != nil {
+			panic(err)
+		}
+		sheet, _, err := gotpi.Load(bytes.NewReader(raw))
+		if err != nil {
+			panic(err)
+		}
+
+		manifest, err := gotpi.ManifestFromFields(func(key string) (string, bool) {
+			v, ok, _ := gotpi.ReadPNGText(raw, key)
+			return v, ok
+		})
+		if err != nil {
+			panic(err)
+		}
+
+		keyImg, err := gotpi.ImportKeySheet(sheet, manifest)
+		if err != nil {
+			panic(err)
+		}
+		save(*keyImpOut, keyImg)
+
+		if *verbose {
+			fmt.Printf("otp key reconstructed to %s\n", *keyImpOut)
+		}
+	}
 }
 
 func termPrint(img image.Image) error {
 	fmt.Printf("\n")
-	return dotmatrix.Print(os.Stdout, resize.Resize(128, 0, img, resize.Lanczos3))
+	return printBraille(os.Stdout, imaging.Resize(img, 128, 0, imaging.Lanczos))
 }
 
+// save writes img to path as PNG, for outputs that are never
+// re-encrypted (e.g. the generated OTP key itself).
 func save(path string, img image.Image) {
 	f, err := os.Create(path)
 	if err != nil {
@@ -115,20 +323,137 @@ func save(path string, img image.Image) {
 	}
 	defer f.Close()
 
-	if err := png.Encode(f, img); err != nil {
+	if err := gotpi.Save(f, img, gotpi.FormatPNG, nil); err != nil {
+		panic(err)
+	}
+}
+
+// saveWithSalt writes img to path as PNG with salt embedded in a
+// gotpi-salt tEXt chunk (hex-encoded, since tEXt holds text), so
+// key-regen can later reproduce the same passphrase-derived key image.
+func saveWithSalt(path string, img image.Image, salt []byte) {
+	var buf bytes.Buffer
+	if err := gotpi.Save(&buf, img, gotpi.FormatPNG, nil); err != nil {
+		panic(err)
+	}
+	out, err := gotpi.InsertPNGText(buf.Bytes(), "gotpi-salt", hex.EncodeToString(salt))
+	if err != nil {
+		panic(err)
+	}
+	if err := os.WriteFile(path, out, 0644); err != nil {
+		panic(err)
+	}
+}
+
+// saveAs writes img to path using format (a --format flag value), falling
+// back to the path's extension when format is empty. It panics with
+// gotpi.ErrLossyOutput if asked to write ciphertext into a lossy container.
+func saveAs(path string, img image.Image, format string) {
+	f, err := os.Create(path)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+
+	var fm gotpi.Format
+	if format != "" {
+		fm, err = gotpi.ParseFormat(format)
+	} else {
+		fm, err = gotpi.FormatFromExt(path)
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	if err := gotpi.Save(f, img, fm, nil); err != nil {
+		panic(err)
+	}
+}
+
+// saveOrientedAs is saveAs plus embedding the EXIF orientation that was
+// normalized away by gotpi.NormalizeForEncrypt, so dec can restore it.
+// Non-PNG outputs don't support the tEXt chunk gotpi uses for this, so the
+// orientation is silently dropped for them (Decrypt still runs, just
+// without un-rotating the result).
+func saveOrientedAs(path string, img image.Image, format string, orientation int) {
+	var fm gotpi.Format
+	var err error
+	if format != "" {
+		fm, err = gotpi.ParseFormat(format)
+	} else {
+		fm, err = gotpi.FormatFromExt(path)
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	if fm != gotpi.FormatPNG || orientation == 1 {
+		saveAs(path, img, format)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := gotpi.Save(&buf, img, fm, nil); err != nil {
+		panic(err)
+	}
+	out, err := gotpi.InsertPNGText(buf.Bytes(), "gotpi-orientation", strconv.Itoa(orientation))
+	if err != nil {
+		panic(err)
+	}
+	if err := os.WriteFile(path, out, 0644); err != nil {
+		panic(err)
+	}
+}
+
+// saveContainerAs encodes img as PNG, embeds c's authenticated manifest in
+// a gtPi chunk, and - if orientation isn't the already-upright default -
+// a gotpi-orientation tEXt chunk alongside it, then writes the result to
+// path. The gtPi container only exists in the PNG chunk space, so a
+// non-PNG format (explicit or inferred from path) is rejected.
+func saveContainerAs(path string, img image.Image, c *gotpi.Container, orientation int, format string) {
+	var fm gotpi.Format
+	var err error
+	if format != "" {
+		fm, err = gotpi.ParseFormat(format)
+	} else {
+		fm, err = gotpi.FormatFromExt(path)
+	}
+	if err != nil {
+		panic(err)
+	}
+	if fm != gotpi.FormatPNG {
+		panic("gotpi: the gtPi container requires a PNG output; pass --legacy to use another format")
+	}
+
+	var buf bytes.Buffer
+	if err := gotpi.Save(&buf, img, fm, nil); err != nil {
+		panic(err)
+	}
+	out, err := gotpi.EmbedContainer(buf.Bytes(), c)
+	if err != nil {
+		panic(err)
+	}
+	if orientation != 1 {
+		out, err = gotpi.InsertPNGText(out, "gotpi-orientation", strconv.Itoa(orientation))
+		if err != nil {
+			panic(err)
+		}
+	}
+	if err := os.WriteFile(path, out, 0644); err != nil {
 		panic(err)
 	}
 }
 
-func imgOpen(path string) image.Image {
+// imgOpen reads and decodes the image at path, reporting its detected format.
+func imgOpen(path string) (image.Image, gotpi.Format) {
 	f, err := os.Open(path)
 	if err != nil {
 		panic(err)
 	}
 	defer f.Close()
-	img, _, err := image.Decode(f)
+	img, format, err := gotpi.Load(f)
 	if err != nil {
 		panic(err)
 	}
-	return img
+	return img, format
 }
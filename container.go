@@ -0,0 +1,195 @@
+package gotpi
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"io"
+
+	"github.com/disintegration/imaging"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	containerMagic   = "GOTPI\x00"
+	containerVersion = 1
+
+	flagRGB = 1 << 0
+
+	containerHeaderLen = 6 + 1 + 1 + 4 + 4 + 8 + 32 // magic+version+flags+w+h+keyFP+hmac
+)
+
+// ErrWrongKey is returned by DecryptContainer when the supplied key image
+// doesn't match the fingerprint recorded in the container, i.e. it isn't
+// the key the ciphertext was encrypted with.
+var ErrWrongKey = errors.New("gotpi: key fingerprint mismatch")
+
+// ErrTampered is returned by DecryptContainer when the ciphertext's HMAC
+// tag doesn't verify, i.e. the file was truncated, corrupted, or modified
+// after encryption.
+var ErrTampered = errors.New("gotpi: container HMAC verification failed")
+
+// Container is the manifest gotpi embeds alongside authenticated
+// ciphertext (in a PNG gtPi chunk, see EmbedContainer/ExtractContainer):
+// enough to detect a wrong key, a dimension mismatch, or tampering before
+// Decrypt ever runs on what might just be noise.
+type Container struct {
+	Version byte
+	RGB     bool
+	Width   uint32
+	Height  uint32
+	KeyFP   [8]byte
+	HMAC    [32]byte
+}
+
+// EncryptToContainer encrypts img with keyImg (see Encrypt) and returns the
+// ciphertext together with a Container manifest recording img's original
+// (pre-resize) dimensions, a fingerprint of the key, and an HMAC over the
+// ciphertext. keyBytes must be the exact encoded bytes keyImg was loaded
+// from, since both the fingerprint and the HMAC key are derived from them.
+func EncryptToContainer(img, keyImg image.Image, keyBytes []byte, rgb bool) (image.Image, *Container) {
+	bounds := img.Bounds()
+	out := Encrypt(img, keyImg, rgb)
+
+	ciphertext := rgbaPixels(out)
+
+	mac := hmac.New(sha256.New, containerMACKey(keyBytes))
+	mac.Write(ciphertext)
+
+	c := &Container{
+		Version: containerVersion,
+		RGB:     rgb,
+		Width:   uint32(bounds.Dx()),
+		Height:  uint32(bounds.Dy()),
+		KeyFP:   keyFingerprint(keyBytes),
+	}
+	copy(c.HMAC[:], mac.Sum(nil))
+
+	return out, c
+}
+
+// DecryptContainer verifies c against keyImg/keyBytes before decrypting:
+// a key fingerprint mismatch returns ErrWrongKey and a failed HMAC returns
+// ErrTampered, both without touching img's pixels. Only once both checks
+// pass is img decrypted and resized back to c's recorded original
+// dimensions.
+func DecryptContainer(img, keyImg image.Image, keyBytes []byte, c *Container) (image.Image, error) {
+	if keyFingerprint(keyBytes) != c.KeyFP {
+		return nil, ErrWrongKey
+	}
+
+	ciphertext := rgbaPixels(img)
+	mac := hmac.New(sha256.New, containerMACKey(keyBytes))
+	mac.Write(ciphertext)
+	if !hmac.Equal(mac.Sum(nil), c.HMAC[:]) {
+		return nil, ErrTampered
+	}
+
+	out := Decrypt(img, keyImg, c.RGB)
+	return imaging.Resize(out, int(c.Width), int(c.Height), imaging.Lanczos), nil
+}
+
+// Marshal encodes c as the TLV blob gotpi stores in a PNG gtPi chunk:
+// magic, version, flags, width, height, key fingerprint, then the HMAC tag.
+func (c *Container) Marshal() []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteString(containerMagic)
+	buf.WriteByte(c.Version)
+
+	var flags byte
+	if c.RGB {
+		flags |= flagRGB
+	}
+	buf.WriteByte(flags)
+
+	binary.Write(buf, binary.BigEndian, c.Width)
+	binary.Write(buf, binary.BigEndian, c.Height)
+	buf.Write(c.KeyFP[:])
+	buf.Write(c.HMAC[:])
+	return buf.Bytes()
+}
+
+// UnmarshalContainer parses the TLV blob produced by Container.Marshal.
+func UnmarshalContainer(data []byte) (*Container, error) {
+	if len(data) != containerHeaderLen {
+		return nil, errors.New("gotpi: malformed container header")
+	}
+	if string(data[:len(containerMagic)]) != containerMagic {
+		return nil, errors.New("gotpi: bad container magic")
+	}
+
+	pos := len(containerMagic)
+	c := &Container{Version: data[pos]}
+	pos++
+
+	flags := data[pos]
+	pos++
+	c.RGB = flags&flagRGB != 0
+
+	c.Width = binary.BigEndian.Uint32(data[pos:])
+	pos += 4
+	c.Height = binary.BigEndian.Uint32(data[pos:])
+	pos += 4
+
+	copy(c.KeyFP[:], data[pos:pos+8])
+	pos += 8
+	copy(c.HMAC[:], data[pos:pos+32])
+
+	return c, nil
+}
+
+// EmbedContainer returns png (PNG-encoded bytes, e.g. from Save) with c's
+// manifest stored in a gtPi chunk. The lowercase first letter marks it
+// ancillary and the lowercase last letter safe-to-copy, so the file stays
+// a valid, ordinary PNG.
+func EmbedContainer(png []byte, c *Container) ([]byte, error) {
+	return InsertPNGChunk(png, "gtPi", c.Marshal())
+}
+
+// ExtractContainer reads and parses the gtPi chunk from png.
+func ExtractContainer(png []byte) (*Container, error) {
+	data, ok, err := ReadPNGChunk(png, "gtPi")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("gotpi: no gtPi container chunk present")
+	}
+	return UnmarshalContainer(data)
+}
+
+// keyFingerprint derives the 8-byte fingerprint of a key image's encoded
+// bytes used to detect a wrong key without storing or leaking the key
+// itself.
+func keyFingerprint(keyBytes []byte) [8]byte {
+	sum := sha256.Sum256(keyBytes)
+	var fp [8]byte
+	copy(fp[:], sum[:8])
+	return fp
+}
+
+// containerMACKey derives the HMAC key for a container from the OTP key
+// bytes via HKDF, so the encryption key and the authentication key are
+// never the same bytes.
+func containerMACKey(keyBytes []byte) []byte {
+	h := hkdf.New(sha256.New, keyBytes, nil, []byte("gotpi-container-mac"))
+	out := make([]byte, 32)
+	if _, err := io.ReadFull(h, out); err != nil {
+		panic(err) // hkdf only fails if keyLen is unreasonably large
+	}
+	return out
+}
+
+// rgbaPixels normalizes img to *image.RGBA and returns its raw pixel bytes,
+// giving EncryptToContainer/DecryptContainer a stable byte representation
+// to HMAC regardless of img's concrete type.
+func rgbaPixels(img image.Image) []byte {
+	b := img.Bounds()
+	rgba := image.NewRGBA(b)
+	draw.Draw(rgba, b, img, b.Min, draw.Src)
+	return rgba.Pix
+}
@@ -0,0 +1,98 @@
+package gotpi
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+func testImages(t *testing.T) (img, key image.Image, keyBytes []byte) {
+	t.Helper()
+	img = KeyGen(32, true)
+	key = KeyGen(32, true)
+
+	var buf bytes.Buffer
+	if err := Save(&buf, key, FormatPNG, nil); err != nil {
+		t.Fatalf("Save key: %v", err)
+	}
+	return img, key, buf.Bytes()
+}
+
+func TestContainerRoundTrip(t *testing.T) {
+	img, key, keyBytes := testImages(t)
+
+	enc, c := EncryptToContainer(img, key, keyBytes, true)
+	out, err := DecryptContainer(enc, key, keyBytes, c)
+	if err != nil {
+		t.Fatalf("DecryptContainer: %v", err)
+	}
+
+	want := rgbaPixels(img)
+	got := rgbaPixels(out)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("round-tripped image doesn't match original")
+	}
+}
+
+func TestContainerWrongKey(t *testing.T) {
+	img, key, keyBytes := testImages(t)
+	_, wrongKey, wrongKeyBytes := testImages(t)
+
+	enc, c := EncryptToContainer(img, key, keyBytes, true)
+	if _, err := DecryptContainer(enc, wrongKey, wrongKeyBytes, c); err != ErrWrongKey {
+		t.Fatalf("got err %v, want ErrWrongKey", err)
+	}
+}
+
+func TestContainerTampered(t *testing.T) {
+	img, key, keyBytes := testImages(t)
+
+	enc, c := EncryptToContainer(img, key, keyBytes, true)
+	tampered := toRGBA(enc)
+	tampered.Pix[0] ^= 0xFF
+
+	if _, err := DecryptContainer(tampered, key, keyBytes, c); err != ErrTampered {
+		t.Fatalf("got err %v, want ErrTampered", err)
+	}
+}
+
+func TestContainerMarshalRoundTrip(t *testing.T) {
+	c := &Container{
+		Version: containerVersion,
+		RGB:     true,
+		Width:   64,
+		Height:  48,
+	}
+	copy(c.KeyFP[:], []byte{1, 2, 3, 4, 5, 6, 7, 8})
+	copy(c.HMAC[:], bytes.Repeat([]byte{0xAB}, 32))
+
+	got, err := UnmarshalContainer(c.Marshal())
+	if err != nil {
+		t.Fatalf("UnmarshalContainer: %v", err)
+	}
+	if *got != *c {
+		t.Fatalf("got %+v, want %+v", got, c)
+	}
+}
+
+func TestEmbedExtractContainer(t *testing.T) {
+	img, key, keyBytes := testImages(t)
+	enc, c := EncryptToContainer(img, key, keyBytes, true)
+
+	var buf bytes.Buffer
+	if err := Save(&buf, enc, FormatPNG, nil); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	withChunk, err := EmbedContainer(buf.Bytes(), c)
+	if err != nil {
+		t.Fatalf("EmbedContainer: %v", err)
+	}
+
+	got, err := ExtractContainer(withChunk)
+	if err != nil {
+		t.Fatalf("ExtractContainer: %v", err)
+	}
+	if *got != *c {
+		t.Fatalf("got %+v, want %+v", got, c)
+	}
+}
@@ -0,0 +1,120 @@
+package gotpi
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// Format identifies an image container/codec that gotpi can read or write.
+type Format string
+
+const (
+	FormatPNG  Format = "png"
+	FormatJPEG Format = "jpeg"
+	FormatGIF  Format = "gif"
+	FormatTIFF Format = "tiff"
+	FormatBMP  Format = "bmp"
+	FormatWebP Format = "webp"
+)
+
+// ErrLossyOutput is returned by Save when asked to write to a lossy
+// container. Re-encoding ciphertext through a lossy codec rounds pixel
+// values and destroys it, so lossy formats are only accepted as Load
+// inputs (to be re-encoded losslessly), never as Save targets.
+var ErrLossyOutput = errors.New("gotpi: refusing to use a lossy format as an encryption output")
+
+func (f Format) lossy() bool {
+	return f == FormatJPEG || f == FormatWebP || f == FormatGIF
+}
+
+// EncodeOptions controls how Save encodes an image for a given Format.
+type EncodeOptions struct {
+	// JPEGQuality is passed to image/jpeg when Format is FormatJPEG. Zero
+	// uses jpeg.DefaultQuality.
+	JPEGQuality int
+	// AllowLossyOutput bypasses the lossy-output guard for callers that
+	// explicitly accept the risk (e.g. saving a plain, non-ciphertext image).
+	AllowLossyOutput bool
+}
+
+// ParseFormat maps a --format flag value or filename extension to a Format.
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimPrefix(s, ".")) {
+	case "png":
+		return FormatPNG, nil
+	case "jpg", "jpeg":
+		return FormatJPEG, nil
+	case "gif":
+		return FormatGIF, nil
+	case "tif", "tiff":
+		return FormatTIFF, nil
+	case "bmp":
+		return FormatBMP, nil
+	case "webp":
+		return FormatWebP, nil
+	default:
+		return "", fmt.Errorf("gotpi: unsupported format %q", s)
+	}
+}
+
+// FormatFromExt is a convenience wrapper around ParseFormat for file paths.
+func FormatFromExt(path string) (Format, error) {
+	return ParseFormat(filepath.Ext(path))
+}
+
+// Load decodes an image from r and reports which Format it was stored in.
+func Load(r io.Reader) (image.Image, Format, error) {
+	img, name, err := image.Decode(r)
+	if err != nil {
+		return nil, "", err
+	}
+	f, err := ParseFormat(name)
+	if err != nil {
+		return nil, "", err
+	}
+	return img, f, nil
+}
+
+// Save encodes img as f and writes it to w. opts may be nil to use defaults.
+// Writing a lossy format (JPEG, WebP, GIF) is refused unless
+// opts.AllowLossyOutput is set, since re-encoding ciphertext through a lossy
+// codec destroys it; use ErrLossyOutput to detect this case.
+func Save(w io.Writer, img image.Image, f Format, opts *EncodeOptions) error {
+	if opts == nil {
+		opts = &EncodeOptions{}
+	}
+	if f.lossy() && !opts.AllowLossyOutput {
+		return ErrLossyOutput
+	}
+	switch f {
+	case FormatPNG:
+		return png.Encode(w, img)
+	case FormatJPEG:
+		q := opts.JPEGQuality
+		if q == 0 {
+			q = jpeg.DefaultQuality
+		}
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: q})
+	case FormatGIF:
+		return gif.Encode(w, img, nil)
+	case FormatTIFF:
+		return tiff.Encode(w, img, nil)
+	case FormatBMP:
+		return bmp.Encode(w, img)
+	case FormatWebP:
+		return errors.New("gotpi: golang.org/x/image/webp only supports decoding, not encoding")
+	default:
+		return fmt.Errorf("gotpi: unknown format %q", f)
+	}
+}
@@ -0,0 +1,65 @@
+package gotpi
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+func TestFormatRoundTrip(t *testing.T) {
+	img := KeyGen(8, true)
+	for _, f := range []Format{FormatPNG, FormatTIFF, FormatBMP} {
+		var buf bytes.Buffer
+		if err := Save(&buf, img, f, nil); err != nil {
+			t.Fatalf("Save(%s): %v", f, err)
+		}
+		got, gotFormat, err := Load(&buf)
+		if err != nil {
+			t.Fatalf("Load(%s): %v", f, err)
+		}
+		if gotFormat != f {
+			t.Fatalf("Load reported format %q, want %q", gotFormat, f)
+		}
+		if !bytes.Equal(rgbaPixels(got), rgbaPixels(img)) {
+			t.Fatalf("%s round-trip altered pixels", f)
+		}
+	}
+}
+
+// TestFormatLossyRefused checks that Save refuses every lossy format by
+// default, and that AllowLossyOutput overrides the refusal.
+func TestFormatLossyRefused(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for _, f := range []Format{FormatJPEG, FormatWebP, FormatGIF} {
+		var buf bytes.Buffer
+		if err := Save(&buf, img, f, nil); err != ErrLossyOutput {
+			t.Fatalf("Save(%s, nil): got err %v, want ErrLossyOutput", f, err)
+		}
+		if f == FormatWebP {
+			continue // golang.org/x/image/webp only supports decoding
+		}
+		buf.Reset()
+		if err := Save(&buf, img, f, &EncodeOptions{AllowLossyOutput: true}); err != nil {
+			t.Fatalf("Save(%s, AllowLossyOutput): %v", f, err)
+		}
+	}
+}
+
+// TestFormatGIFDestroysCiphertext documents why FormatGIF must be treated
+// as lossy: GIF's 256-color indexed palette quantizes an OTP-encrypted
+// image's effectively-random pixels so badly that a round trip comes back
+// unrecoverable, even though gif.Encode itself reports no error.
+func TestFormatGIFDestroysCiphertext(t *testing.T) {
+	img := KeyGen(32, true)
+	var buf bytes.Buffer
+	if err := Save(&buf, img, FormatGIF, &EncodeOptions{AllowLossyOutput: true}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	got, _, err := Load(&buf)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if bytes.Equal(rgbaPixels(got), rgbaPixels(img)) {
+		t.Fatalf("expected GIF round-trip to alter a random-pixel image's bytes")
+	}
+}
@@ -0,0 +1,88 @@
+package gotpi
+
+import (
+	"bytes"
+	"image"
+	"io"
+
+	"github.com/micheledinelli/gotpi/transform"
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// NormalizeForEncrypt reads src, decodes it, and - if it carries an EXIF
+// Orientation tag other than 1 (already upright) - applies the matching
+// flip/rotation so the returned image's pixels are stored exactly as they
+// are rendered. The original orientation value (1 if none was present) is
+// returned so the caller can stash it alongside the ciphertext and reverse
+// the transform on Decrypt via RestoreOrientation. Without this, a phone
+// photo whose pixels are stored rotated gets encrypted in that raw layout
+// and comes back scrambled relative to how the photo was actually viewed.
+func NormalizeForEncrypt(src io.Reader) (image.Image, int, error) {
+	raw, err := io.ReadAll(src)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	orientation := 1
+	if x, err := exif.Decode(bytes.NewReader(raw)); err == nil {
+		if tag, err := x.Get(exif.Orientation); err == nil {
+			if v, err := tag.Int(0); err == nil {
+				orientation = v
+			}
+		}
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return applyOrientation(img, orientation), orientation, nil
+}
+
+// RestoreOrientation reverses the transform NormalizeForEncrypt applied for
+// the given orientation value, returning the image to its original
+// (possibly rotated) pixel layout.
+func RestoreOrientation(img image.Image, orientation int) image.Image {
+	switch orientation {
+	case 2:
+		return transform.FlipH(img)
+	case 3:
+		return transform.Rotate180(img)
+	case 4:
+		return transform.FlipV(img)
+	case 5:
+		return transform.FlipH(transform.Rotate90(img))
+	case 6:
+		return transform.Rotate270(img)
+	case 7:
+		return transform.FlipH(transform.Rotate270(img))
+	case 8:
+		return transform.Rotate90(img)
+	default:
+		return img
+	}
+}
+
+// applyOrientation applies the EXIF Orientation transform needed to bring a
+// stored image to an upright layout; it's the inverse of RestoreOrientation.
+func applyOrientation(img image.Image, orientation int) image.Image {
+	switch orientation {
+	case 2:
+		return transform.FlipH(img)
+	case 3:
+		return transform.Rotate180(img)
+	case 4:
+		return transform.FlipV(img)
+	case 5:
+		return transform.Rotate270(transform.FlipH(img))
+	case 6:
+		return transform.Rotate90(img)
+	case 7:
+		return transform.Rotate90(transform.FlipH(img))
+	case 8:
+		return transform.Rotate270(img)
+	default:
+		return img
+	}
+}
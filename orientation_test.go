@@ -0,0 +1,39 @@
+package gotpi
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// markedImage returns a rectangular (non-square) image with a distinct
+// color in each corner, so a flip/rotate composition that's subtly wrong -
+// e.g. swapping two of the EXIF orientations' transforms - shows up as a
+// mismatch instead of accidentally round-tripping on a symmetric image.
+func markedImage() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 6, 4))
+	corners := map[image.Point]color.RGBA{
+		{0, 0}: {255, 0, 0, 255},
+		{5, 0}: {0, 255, 0, 255},
+		{0, 3}: {0, 0, 255, 255},
+		{5, 3}: {255, 255, 0, 255},
+	}
+	for p, c := range corners {
+		img.Set(p.X, p.Y, c)
+	}
+	return img
+}
+
+func TestOrientationRoundTrip(t *testing.T) {
+	upright := markedImage()
+	want := rgbaPixels(upright)
+
+	for o := 1; o <= 8; o++ {
+		stored := RestoreOrientation(upright, o)
+		got := applyOrientation(stored, o)
+		if !bytes.Equal(rgbaPixels(got), want) {
+			t.Fatalf("orientation %d: applyOrientation(RestoreOrientation(img, %d), %d) != img", o, o, o)
+		}
+	}
+}
@@ -2,11 +2,13 @@ package gotpi
 
 import (
 	"crypto/rand"
+	"crypto/subtle"
 	"image"
 	"image/color"
+	"image/draw"
 	_ "image/png"
 
-	"github.com/nfnt/resize"
+	"github.com/disintegration/imaging"
 )
 
 // Encrypt encrypts an input image using a key image.
@@ -18,7 +20,7 @@ import (
 func Encrypt(img image.Image, keyImg image.Image, rgb bool) image.Image {
 	bounds := keyImg.Bounds()
 	out := image.NewRGBA(keyImg.Bounds())
-	img = resize.Resize(uint(bounds.Dx()), uint(bounds.Dy()), img, resize.Lanczos3)
+	img = imaging.Resize(img, bounds.Dx(), bounds.Dy(), imaging.Lanczos)
 	if rgb {
 		encRGB(img, keyImg, out)
 	} else {
@@ -67,23 +69,51 @@ func encBW(img, k image.Image, out *image.RGBA) {
 // encRGB encrypts an image using RGB channel-wise XOR encryption.
 // Each color channel (R, G, B) of the source image is XORed with the
 // corresponding channel of the key image.
-// The alpha channel is set to fully opaque
+// The alpha channel is set to fully opaque.
+//
+// img and k are normalized to *image.RGBA once and then XORed a whole
+// row of packed RGBA bytes at a time via subtle.XORBytes, rather than
+// converting and setting one pixel at a time - the same work, done at
+// roughly memcpy speed instead of paying a color.Color conversion and an
+// interface dispatch per pixel.
 func encRGB(img, k image.Image, out *image.RGBA) {
 	bounds := k.Bounds()
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			r, g, b, _ := img.At(x, y).RGBA()
-			kr, kg, kb, _ := k.At(x, y).RGBA()
+	srcRGBA := toRGBA(img)
+	keyRGBA := toRGBA(k)
 
-			resR := uint8(r>>8) ^ uint8(kr>>8)
-			resG := uint8(g>>8) ^ uint8(kg>>8)
-			resB := uint8(b>>8) ^ uint8(kb>>8)
-
-			out.Set(x, y, color.RGBA{resR, resG, resB, 255})
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		outRow := rowPix(out, bounds.Min.X, bounds.Max.X, y)
+		srcRow := rowPix(srcRGBA, bounds.Min.X, bounds.Max.X, y)
+		keyRow := rowPix(keyRGBA, bounds.Min.X, bounds.Max.X, y)
+		subtle.XORBytes(outRow, srcRow, keyRow)
+		for i := 3; i < len(outRow); i += 4 {
+			outRow[i] = 0xff
 		}
 	}
 }
 
+// toRGBA normalizes img to *image.RGBA, preserving its bounds, so the
+// result shares a coordinate system with images that were already
+// *image.RGBA (img and k are otherwise assumed to agree on bounds by the
+// caller, exactly as the rest of this file always has).
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba
+	}
+	b := img.Bounds()
+	rgba := image.NewRGBA(b)
+	draw.Draw(rgba, b, img, b.Min, draw.Src)
+	return rgba
+}
+
+// rowPix returns the packed RGBA bytes of rgba's row y between columns
+// x0 (inclusive) and x1 (exclusive), as a slice directly into rgba.Pix.
+func rowPix(rgba *image.RGBA, x0, x1, y int) []byte {
+	i0 := rgba.PixOffset(x0, y)
+	i1 := rgba.PixOffset(x1-1, y) + 4
+	return rgba.Pix[i0:i1]
+}
+
 // KeyGen generates a new one-time pad (OTP) key image.
 // The key image is always square with dimensions (kw × kw) which
 // stands for key width.
@@ -0,0 +1,83 @@
+package gotpi
+
+import (
+	"image"
+	"image/color"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20"
+)
+
+// KeyGenFromPassphrase deterministically derives an OTP key image from a
+// passphrase and salt: passphrase and salt are stretched into a 32-byte
+// seed with Argon2id (time=3, memory=64MiB, threads=4), which seeds a
+// ChaCha20 keystream expanded to width*width*{1,3} bytes and mapped to
+// pixels exactly as keyGenBW/keyGenRGB do.
+//
+// This is NOT an information-theoretically secure OTP key. KeyGen's
+// output is true randomness, unconditionally secure when used once, no
+// matter an attacker's compute. KeyGenFromPassphrase's output is the
+// output of a stream cipher keyed by a password - its secrecy reduces to
+// the strength of passphrase plus ChaCha20, which is an ordinary
+// computational assumption, not a one-time pad guarantee. Reach for this
+// only when memorizing a passphrase matters more than perfect secrecy;
+// anyone who needs the real guarantee should keep using KeyGen and
+// protect the resulting key image file instead.
+//
+// salt need not be secret, only unique per key - callers are expected to
+// persist it alongside the derived key (cmd/gotpi does this via a
+// "gotpi-salt" tEXt chunk) so the same key can be reproduced later from
+// the same passphrase.
+func KeyGenFromPassphrase(passphrase []byte, salt []byte, width int, rgb bool) image.Image {
+	channels := 1
+	if rgb {
+		channels = 3
+	}
+	seed := argon2.IDKey(passphrase, salt, 3, 64*1024, 4, chacha20.KeySize)
+
+	stream := make([]byte, width*width*channels)
+	c, err := chacha20.NewUnauthenticatedCipher(seed, make([]byte, chacha20.NonceSize))
+	if err != nil {
+		panic(err) // seed is always chacha20.KeySize bytes long
+	}
+	c.XORKeyStream(stream, stream)
+
+	if rgb {
+		return keyFromStreamRGB(width, width, stream)
+	}
+	return keyFromStreamBW(width, width, stream)
+}
+
+// keyFromStreamBW builds a monochrome key image the same way keyGenBW
+// does, consuming one byte of stream per pixel instead of reading fresh
+// bytes from crypto/rand.
+func keyFromStreamBW(width, height int, stream []byte) image.Image {
+	k := image.NewNRGBA(image.Rect(0, 0, width, height))
+	i := 0
+	for y := range height {
+		for x := range width {
+			if stream[i]&1 == 0 {
+				k.Set(x, y, Black)
+			} else {
+				k.Set(x, y, White)
+			}
+			i++
+		}
+	}
+	return k
+}
+
+// keyFromStreamRGB builds an RGB key image the same way keyGenRGB does,
+// consuming three bytes of stream per pixel instead of reading fresh
+// bytes from crypto/rand.
+func keyFromStreamRGB(width, height int, stream []byte) image.Image {
+	k := image.NewNRGBA(image.Rect(0, 0, width, height))
+	i := 0
+	for y := range height {
+		for x := range width {
+			k.Set(x, y, color.RGBA{stream[i], stream[i+1], stream[i+2], 255})
+			i += 3
+		}
+	}
+	return k
+}
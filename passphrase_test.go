@@ -0,0 +1,29 @@
+package gotpi
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestKeyGenFromPassphraseDeterministic(t *testing.T) {
+	passphrase := []byte("correct horse battery staple")
+	salt := []byte("fixed-test-salt-0123456789ab")
+
+	for _, rgb := range []bool{false, true} {
+		a := KeyGenFromPassphrase(passphrase, salt, 16, rgb)
+		b := KeyGenFromPassphrase(passphrase, salt, 16, rgb)
+		if !bytes.Equal(rgbaPixels(a), rgbaPixels(b)) {
+			t.Fatalf("rgb=%v: two calls with the same passphrase+salt produced different key bytes", rgb)
+		}
+	}
+}
+
+func TestKeyGenFromPassphraseSaltChangesKey(t *testing.T) {
+	passphrase := []byte("correct horse battery staple")
+
+	a := KeyGenFromPassphrase(passphrase, []byte("salt-one-0123456789abcdef"), 16, true)
+	b := KeyGenFromPassphrase(passphrase, []byte("salt-two-0123456789abcdef"), 16, true)
+	if bytes.Equal(rgbaPixels(a), rgbaPixels(b)) {
+		t.Fatalf("different salts produced the same key bytes")
+	}
+}
@@ -0,0 +1,317 @@
+package gotpi
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrUnsupportedStreamPNG is returned by the pngStreamReader used by
+// EncryptStream when asked to decode a PNG shape it doesn't implement -
+// paletted, 16-bit, or interlaced. Streaming only needs to cover what
+// gotpi itself produces (8-bit, non-interlaced grayscale/RGB/RGBA), so
+// anything else should fall back to Encrypt, which decodes the image
+// fully via the standard library instead.
+var ErrUnsupportedStreamPNG = errors.New("gotpi: streaming PNG codec only supports 8-bit, non-interlaced grayscale/RGB/RGBA")
+
+// pngStreamReader decodes a non-interlaced, 8-bit PNG one row at a time,
+// holding only the current and previous unfiltered rows in memory - not
+// the whole image - so EncryptStream's peak memory stays proportional to
+// image width and tile height, not image height.
+type pngStreamReader struct {
+	Width, Height int
+	channels      int // 1 (gray), 3 (RGB) or 4 (RGBA)
+
+	zr   io.ReadCloser
+	prev []byte // previous unfiltered row, nil before the first row
+	y    int
+}
+
+func newPNGStreamReader(r io.Reader) (*pngStreamReader, error) {
+	var sig [8]byte
+	if _, err := io.ReadFull(r, sig[:]); err != nil {
+		return nil, fmt.Errorf("gotpi: reading PNG signature: %w", err)
+	}
+	if !bytes.Equal(sig[:], pngSignature) {
+		return nil, errors.New("gotpi: not a PNG stream")
+	}
+
+	typ, data, err := readPNGChunkStream(r)
+	if err != nil {
+		return nil, err
+	}
+	if typ != "IHDR" || len(data) != 13 {
+		return nil, errors.New("gotpi: PNG missing IHDR")
+	}
+	width := int(binary.BigEndian.Uint32(data[0:4]))
+	height := int(binary.BigEndian.Uint32(data[4:8]))
+	bitDepth := data[8]
+	colorType := data[9]
+	interlace := data[12]
+	if bitDepth != 8 || interlace != 0 {
+		return nil, ErrUnsupportedStreamPNG
+	}
+
+	var channels int
+	switch colorType {
+	case 0:
+		channels = 1
+	case 2:
+		channels = 3
+	case 6:
+		channels = 4
+	default:
+		return nil, ErrUnsupportedStreamPNG
+	}
+
+	idat := &idatReader{r: r}
+	zr, err := zlib.NewReader(idat)
+	if err != nil {
+		return nil, fmt.Errorf("gotpi: opening PNG IDAT stream: %w", err)
+	}
+
+	return &pngStreamReader{Width: width, Height: height, channels: channels, zr: zr}, nil
+}
+
+// ReadRowRGBA returns row y's pixels expanded to RGBA (width*4 bytes,
+// alpha forced opaque for gray/RGB sources), advancing the decoder by one
+// row. Rows must be read in order.
+func (d *pngStreamReader) ReadRowRGBA() ([]byte, error) {
+	if d.y >= d.Height {
+		return nil, io.EOF
+	}
+	rowLen := d.Width * d.channels
+	filtered := make([]byte, 1+rowLen)
+	if _, err := io.ReadFull(d.zr, filtered); err != nil {
+		return nil, fmt.Errorf("gotpi: reading PNG row %d: %w", d.y, err)
+	}
+
+	row := make([]byte, rowLen)
+	if err := unfilterRow(filtered[0], filtered[1:], d.prev, row, d.channels); err != nil {
+		return nil, err
+	}
+	d.prev = row
+	d.y++
+
+	return expandToRGBA(row, d.channels), nil
+}
+
+// unfilterRow reverses the PNG row filter (None/Sub/Up/Average/Paeth),
+// writing the reconstructed bytes into out. prev is the previous row's
+// reconstructed bytes, or nil for the first row.
+func unfilterRow(filterType byte, filt, prev, out []byte, bpp int) error {
+	paeth := func(a, b, c byte) byte {
+		p := int(a) + int(b) - int(c)
+		pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+		switch {
+		case pa <= pb && pa <= pc:
+			return a
+		case pb <= pc:
+			return b
+		default:
+			return c
+		}
+	}
+	at := func(buf []byte, i int) byte {
+		if i < 0 || buf == nil {
+			return 0
+		}
+		return buf[i]
+	}
+
+	for i := range out {
+		a := at(out, i-bpp)
+		b := at(prev, i)
+		c := at(prev, i-bpp)
+		switch filterType {
+		case 0:
+			out[i] = filt[i]
+		case 1:
+			out[i] = filt[i] + a
+		case 2:
+			out[i] = filt[i] + b
+		case 3:
+			out[i] = filt[i] + byte((int(a)+int(b))/2)
+		case 4:
+			out[i] = filt[i] + paeth(a, b, c)
+		default:
+			return fmt.Errorf("gotpi: unsupported PNG filter type %d", filterType)
+		}
+	}
+	return nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// expandToRGBA widens a decoded row of the given channel count to RGBA.
+func expandToRGBA(row []byte, channels int) []byte {
+	if channels == 4 {
+		return row
+	}
+	out := make([]byte, len(row)/channels*4)
+	for i, o := 0, 0; i < len(row); i, o = i+channels, o+4 {
+		switch channels {
+		case 1:
+			out[o], out[o+1], out[o+2] = row[i], row[i], row[i]
+		case 3:
+			out[o], out[o+1], out[o+2] = row[i], row[i+1], row[i+2]
+		}
+		out[o+3] = 0xff
+	}
+	return out
+}
+
+// idatReader presents a PNG's concatenated IDAT chunk payloads as a single
+// io.Reader, transparently pulling in the next IDAT chunk (skipping any
+// non-IDAT ancillary chunks interleaved before it) as each one is drained.
+type idatReader struct {
+	r   io.Reader
+	buf []byte
+	err error
+}
+
+func (c *idatReader) Read(p []byte) (int, error) {
+	for len(c.buf) == 0 {
+		if c.err != nil {
+			return 0, c.err
+		}
+		typ, data, err := readPNGChunkStream(c.r)
+		if err != nil {
+			c.err = err
+			return 0, err
+		}
+		switch typ {
+		case "IDAT":
+			c.buf = data
+		case "IEND":
+			c.err = io.EOF
+		}
+	}
+	n := copy(p, c.buf)
+	c.buf = c.buf[n:]
+	return n, nil
+}
+
+// readPNGChunkStream reads one length-prefixed chunk (type, data, CRC) from
+// r, the streaming counterpart to scanPNGChunks which instead works over
+// an already fully-read []byte.
+func readPNGChunkStream(r io.Reader) (typ string, data []byte, err error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return "", nil, err
+	}
+	length := binary.BigEndian.Uint32(header[0:4])
+	typ = string(header[4:8])
+
+	data = make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return "", nil, err
+	}
+	var crc [4]byte
+	if _, err := io.ReadFull(r, crc[:]); err != nil {
+		return "", nil, err
+	}
+	return typ, data, nil
+}
+
+// pngStreamWriter encodes an 8-bit, non-interlaced RGBA image as PNG one
+// row at a time, flushing compressed bytes into a new IDAT chunk every
+// flushRows rows instead of buffering the whole compressed stream, so
+// EncryptStream's output side stays bounded by tile height too.
+type pngStreamWriter struct {
+	w              io.Writer
+	width, height  int
+	flushRows      int
+	rowsSinceFlush int
+	compressed     bytes.Buffer
+	zw             *zlib.Writer
+	err            error
+}
+
+func newPNGStreamWriter(w io.Writer, width, height, flushRows int) (*pngStreamWriter, error) {
+	if _, err := w.Write(pngSignature); err != nil {
+		return nil, err
+	}
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:4], uint32(width))
+	binary.BigEndian.PutUint32(ihdr[4:8], uint32(height))
+	ihdr[8] = 8 // bit depth
+	ihdr[9] = 6 // color type: truecolor with alpha
+	// ihdr[10], [11], [12] (compression, filter, interlace) are already 0.
+	if _, err := w.Write(makePNGChunk("IHDR", ihdr)); err != nil {
+		return nil, err
+	}
+
+	p := &pngStreamWriter{w: w, width: width, height: height, flushRows: flushRows}
+	p.zw = zlib.NewWriter(&p.compressed)
+	return p, nil
+}
+
+// WriteRowRGBA writes one row of RGBA pixels (width*4 bytes), always using
+// filter type 0 (None) - simplest to implement correctly, and no worse
+// than any other filter on the high-entropy ciphertext rows this is
+// actually used for.
+func (p *pngStreamWriter) WriteRowRGBA(row []byte) error {
+	if p.err != nil {
+		return p.err
+	}
+	if _, err := p.zw.Write([]byte{0}); err != nil {
+		p.err = err
+		return err
+	}
+	if _, err := p.zw.Write(row); err != nil {
+		p.err = err
+		return err
+	}
+
+	p.rowsSinceFlush++
+	if p.rowsSinceFlush >= p.flushRows {
+		if err := p.flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *pngStreamWriter) flush() error {
+	if err := p.zw.Flush(); err != nil {
+		p.err = err
+		return err
+	}
+	p.rowsSinceFlush = 0
+	if p.compressed.Len() == 0 {
+		return nil
+	}
+	if _, err := p.w.Write(makePNGChunk("IDAT", p.compressed.Bytes())); err != nil {
+		p.err = err
+		return err
+	}
+	p.compressed.Reset()
+	return nil
+}
+
+// Close flushes any remaining compressed data and writes the IEND chunk.
+func (p *pngStreamWriter) Close() error {
+	if p.err != nil {
+		return p.err
+	}
+	if err := p.zw.Close(); err != nil {
+		return err
+	}
+	if p.compressed.Len() > 0 {
+		if _, err := p.w.Write(makePNGChunk("IDAT", p.compressed.Bytes())); err != nil {
+			return err
+		}
+		p.compressed.Reset()
+	}
+	_, err := p.w.Write(makePNGChunk("IEND", nil))
+	return err
+}
@@ -0,0 +1,117 @@
+package gotpi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+)
+
+var pngSignature = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}
+
+// InsertPNGChunk takes PNG-encoded bytes (as produced by Save with
+// FormatPNG) and returns a copy with an extra ancillary chunk of the given
+// type inserted immediately before the IEND chunk. chunkType must follow
+// the PNG naming convention (lowercase first letter = ancillary); gotpi
+// uses this for tEXt metadata (see InsertPNGText) and its own gtPi
+// container manifest (see container.go).
+func InsertPNGChunk(png []byte, chunkType string, data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(png, pngSignature) {
+		return nil, errors.New("gotpi: not a PNG stream")
+	}
+
+	chunk := makePNGChunk(chunkType, data)
+
+	iend := bytes.LastIndex(png, []byte("IEND"))
+	if iend < 4 {
+		return nil, errors.New("gotpi: missing IEND chunk")
+	}
+	insertAt := iend - 4 // back up over IEND's 4-byte length field
+
+	out := make([]byte, 0, len(png)+len(chunk))
+	out = append(out, png[:insertAt]...)
+	out = append(out, chunk...)
+	out = append(out, png[insertAt:]...)
+	return out, nil
+}
+
+// scanPNGChunks walks the chunks following png's signature, calling fn
+// with each chunk's type and data until fn returns true (found what it's
+// looking for) or the IEND chunk is reached. It returns an error if a
+// chunk's length field would run past the end of png, so callers never
+// slice out of bounds on truncated or corrupted input.
+func scanPNGChunks(png []byte, fn func(typ string, data []byte) (stop bool)) error {
+	pos := len(pngSignature)
+	for pos+8 <= len(png) {
+		length := int(binary.BigEndian.Uint32(png[pos : pos+4]))
+		if pos+8+length > len(png) {
+			return errors.New("gotpi: truncated or corrupt PNG chunk")
+		}
+		typ := string(png[pos+4 : pos+8])
+		data := png[pos+8 : pos+8+length]
+
+		if fn(typ, data) || typ == "IEND" {
+			return nil
+		}
+		pos += 8 + length + 4 // length field + type + data + crc
+	}
+	return nil
+}
+
+// ReadPNGChunk scans PNG-encoded bytes for the first chunk of the given
+// type and returns its data, or ok == false if no such chunk is present.
+func ReadPNGChunk(png []byte, chunkType string) (data []byte, ok bool, err error) {
+	if !bytes.HasPrefix(png, pngSignature) {
+		return nil, false, errors.New("gotpi: not a PNG stream")
+	}
+
+	err = scanPNGChunks(png, func(typ string, chunkData []byte) bool {
+		if typ == chunkType {
+			data, ok = chunkData, true
+			return true
+		}
+		return false
+	})
+	return data, ok, err
+}
+
+// InsertPNGText inserts a tEXt chunk, keyword=key, ahead of IEND. This is
+// how metadata such as gotpi-orientation (see orientation.go) rides along
+// inside an otherwise ordinary PNG file without needing a sidecar.
+func InsertPNGText(png []byte, key, value string) ([]byte, error) {
+	data := append([]byte(key), 0)
+	data = append(data, []byte(value)...)
+	return InsertPNGChunk(png, "tEXt", data)
+}
+
+// ReadPNGText returns the value of the tEXt chunk named key, or
+// ok == false if no such chunk is present.
+func ReadPNGText(png []byte, key string) (value string, ok bool, err error) {
+	if !bytes.HasPrefix(png, pngSignature) {
+		return "", false, errors.New("gotpi: not a PNG stream")
+	}
+
+	prefix := append([]byte(key), 0)
+	err = scanPNGChunks(png, func(typ string, data []byte) bool {
+		if typ == "tEXt" && bytes.HasPrefix(data, prefix) {
+			value, ok = string(data[len(prefix):]), true
+			return true
+		}
+		return false
+	})
+	return value, ok, err
+}
+
+func makePNGChunk(typ string, data []byte) []byte {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.BigEndian, uint32(len(data)))
+	buf.WriteString(typ)
+	buf.Write(data)
+
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(typ))
+	crc.Write(data)
+	binary.Write(buf, binary.BigEndian, crc.Sum32())
+
+	return buf.Bytes()
+}
@@ -0,0 +1,67 @@
+package gotpi
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+func samplePNG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	if err := Save(&buf, img, FormatPNG, nil); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestPNGTextRoundTrip(t *testing.T) {
+	png, err := InsertPNGText(samplePNG(t), "gotpi-test", "hello")
+	if err != nil {
+		t.Fatalf("InsertPNGText: %v", err)
+	}
+	got, ok, err := ReadPNGText(png, "gotpi-test")
+	if err != nil || !ok {
+		t.Fatalf("ReadPNGText: got (%q, %v, %v)", got, ok, err)
+	}
+	if got != "hello" {
+		t.Fatalf("got %q, want %q", got, "hello")
+	}
+}
+
+func TestReadPNGTextMissing(t *testing.T) {
+	_, ok, err := ReadPNGText(samplePNG(t), "does-not-exist")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ok {
+		t.Fatalf("expected ok == false")
+	}
+}
+
+// TestReadPNGChunkTruncatedLength checks that a chunk whose length field
+// claims more bytes than actually follow it returns an error instead of
+// panicking with a slice-bounds-out-of-range.
+func TestReadPNGChunkTruncatedLength(t *testing.T) {
+	png, err := InsertPNGText(samplePNG(t), "gotpi-test", "hello")
+	if err != nil {
+		t.Fatalf("InsertPNGText: %v", err)
+	}
+
+	// Corrupt the length field of the first chunk after the signature
+	// (IHDR) to claim it runs past the end of the file.
+	corrupt := append([]byte(nil), png...)
+	pos := len(pngSignature)
+	corrupt[pos] = 0xff
+	corrupt[pos+1] = 0xff
+	corrupt[pos+2] = 0xff
+	corrupt[pos+3] = 0xff
+
+	if _, _, err := ReadPNGChunk(corrupt, "tEXt"); err == nil {
+		t.Fatalf("expected an error on a truncated chunk length, got nil")
+	}
+	if _, _, err := ReadPNGText(corrupt, "gotpi-test"); err == nil {
+		t.Fatalf("expected an error on a truncated chunk length, got nil")
+	}
+}
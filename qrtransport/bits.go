@@ -0,0 +1,60 @@
+package qrtransport
+
+// bitWriter accumulates bits MSB-first into a byte slice, the order the
+// QR bit stream (mode indicator, count indicator, data, padding) expects.
+type bitWriter struct {
+	buf      []byte
+	bitCount int
+}
+
+func newBitWriter() *bitWriter {
+	return &bitWriter{}
+}
+
+func (w *bitWriter) writeBits(v uint32, n int) {
+	for i := n - 1; i >= 0; i-- {
+		bit := (v >> uint(i)) & 1
+		byteIdx := w.bitCount / 8
+		for byteIdx >= len(w.buf) {
+			w.buf = append(w.buf, 0)
+		}
+		if bit != 0 {
+			w.buf[byteIdx] |= 1 << uint(7-w.bitCount%8)
+		}
+		w.bitCount++
+	}
+}
+
+func (w *bitWriter) padToByte() {
+	if rem := w.bitCount % 8; rem != 0 {
+		w.writeBits(0, 8-rem)
+	}
+}
+
+func (w *bitWriter) len() int { return w.bitCount }
+
+func (w *bitWriter) bytes() []byte { return w.buf }
+
+// bitReader walks a byte slice MSB-first, mirroring bitWriter.
+type bitReader struct {
+	buf []byte
+	pos int
+}
+
+func newBitReader(buf []byte) *bitReader {
+	return &bitReader{buf: buf}
+}
+
+func (r *bitReader) readBits(n int) uint32 {
+	var v uint32
+	for i := 0; i < n; i++ {
+		byteIdx := r.pos / 8
+		var bit uint32
+		if byteIdx < len(r.buf) {
+			bit = uint32(r.buf[byteIdx]>>uint(7-r.pos%8)) & 1
+		}
+		v = v<<1 | bit
+		r.pos++
+	}
+	return v
+}
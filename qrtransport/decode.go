@@ -0,0 +1,78 @@
+package qrtransport
+
+import "image"
+
+// Decode reads a QR symbol previously rendered by Render at the given
+// scale/quietZone and recovers its original byte-mode payload. version
+// must match what Encode used to build it; the ECC level and mask are
+// recovered from the format-information strips rather than passed in.
+func Decode(img image.Image, version, scale, quietZone int) ([]byte, error) {
+	size := Size(version)
+	m := newMatrix(version, ECCLow)
+	b := img.Bounds()
+
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			px := b.Min.X + (x+quietZone)*scale + scale/2
+			py := b.Min.Y + (y+quietZone)*scale + scale/2
+			r, g, bl, _ := img.At(px, py).RGBA()
+			lum := (r + g + bl) / 3
+			m.modules[y][x] = lum < 0x8000
+		}
+	}
+
+	// Re-derive the function-pattern/data-module split; the format info
+	// strips it marks are left holding the values we just sampled.
+	placeFunctionPatterns(m)
+
+	ecc, mask, err := readFormatInfo(m)
+	if err != nil {
+		return nil, err
+	}
+	m.ECC = ecc
+
+	cap, ok := capacities[version][ecc]
+	if !ok {
+		return nil, errQRUnsupportedVersion
+	}
+
+	positions := dataModulePositions(m)
+	applyMask(m, mask, positions)
+
+	codewords := readCodewords(m, positions, cap.dataCodewords+cap.ecCodewords)
+	corrected, err := rsCorrect(codewords, cap.ecCodewords)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeBitStream(corrected[:cap.dataCodewords])
+}
+
+func readCodewords(m *Matrix, positions []pos, totalCodewords int) []byte {
+	out := make([]byte, totalCodewords)
+	for i, p := range positions {
+		if i/8 >= len(out) {
+			break
+		}
+		if m.modules[p.y][p.x] {
+			out[i/8] |= 1 << uint(7-i%8)
+		}
+	}
+	return out
+}
+
+func decodeBitStream(data []byte) ([]byte, error) {
+	br := newBitReader(data)
+	if mode := br.readBits(4); mode != 0b0100 {
+		return nil, errQRUnsupportedVersion // byte mode is the only one this package writes or reads
+	}
+	count := int(br.readBits(8))
+	if count > len(data) {
+		return nil, errQRDataTooLong
+	}
+	out := make([]byte, count)
+	for i := range out {
+		out[i] = byte(br.readBits(8))
+	}
+	return out, nil
+}
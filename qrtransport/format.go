@@ -0,0 +1,76 @@
+package qrtransport
+
+// eccFormatBits is the format-information encoding of each ECC level,
+// per the QR spec (not the same bit pattern as the ECCLevel enum).
+var eccFormatBits = map[ECCLevel]uint32{
+	ECCLow:      1,
+	ECCMedium:   0,
+	ECCQuartile: 3,
+	ECCHigh:     2,
+}
+
+// placeFormatInfo computes the 15-bit format-information word for ecc and
+// mask (a 5-bit BCH(15,5) codeword masked with 0x5412, per the spec) and
+// writes its two redundant copies into the strips reserveFormatInfo set
+// aside around the top-left finder pattern.
+func placeFormatInfo(m *Matrix, ecc ECCLevel, mask int) {
+	data := eccFormatBits[ecc]<<3 | uint32(mask)
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	rem &= (1 << 10) - 1
+	bits := (data<<10 | rem) ^ 0x5412
+
+	getBit := func(i int) bool { return (bits>>uint(i))&1 != 0 }
+
+	for i := 0; i <= 5; i++ {
+		m.set(8, i, getBit(i), true)
+	}
+	m.set(8, 7, getBit(6), true)
+	m.set(8, 8, getBit(7), true)
+	m.set(7, 8, getBit(8), true)
+	for i := 9; i < 15; i++ {
+		m.set(14-i, 8, getBit(i), true)
+	}
+
+	for i := 0; i < 8; i++ {
+		m.set(m.Size-1-i, 8, getBit(i), true)
+	}
+	for i := 8; i < 15; i++ {
+		m.set(8, m.Size-15+i, getBit(i), true)
+	}
+}
+
+// readFormatInfo reads the first (top-left) format-information copy back
+// out of m and returns the ECC level and mask it encodes.
+func readFormatInfo(m *Matrix) (ECCLevel, int, error) {
+	var bits uint32
+	getBit := func(x, y int) uint32 {
+		if m.modules[y][x] {
+			return 1
+		}
+		return 0
+	}
+
+	for i := 0; i <= 5; i++ {
+		bits |= getBit(8, i) << uint(i)
+	}
+	bits |= getBit(8, 7) << 6
+	bits |= getBit(8, 8) << 7
+	bits |= getBit(7, 8) << 8
+	for i := 9; i < 15; i++ {
+		bits |= getBit(14-i, 8) << uint(i)
+	}
+
+	bits ^= 0x5412
+	data := bits >> 10
+
+	for ecc, code := range eccFormatBits {
+		if code == data>>3 {
+			mask := int(data & 0b111)
+			return ecc, mask, nil
+		}
+	}
+	return 0, 0, errQRFormatInfo
+}
@@ -0,0 +1,273 @@
+package qrtransport
+
+// GF(256) arithmetic over QR's field, generated by the primitive
+// polynomial x^8 + x^4 + x^3 + x^2 + 1 (0x11D), used both to build Reed-
+// Solomon generator polynomials and to encode/correct codewords.
+
+var (
+	gfExp [512]byte // exp[i] = 2^i, doubled up to avoid a mod in multiply
+	gfLog [256]byte
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfLog[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11D
+		}
+	}
+	for i := 255; i < 512; i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+// gfPolyMul multiplies two polynomials over GF(256), coefficients in
+// descending order of degree (matching how Reed-Solomon generator
+// polynomials and message polynomials are represented here).
+func gfPolyMul(a, b []byte) []byte {
+	out := make([]byte, len(a)+len(b)-1)
+	for i, ca := range a {
+		if ca == 0 {
+			continue
+		}
+		for j, cb := range b {
+			out[i+j] ^= gfMul(ca, cb)
+		}
+	}
+	return out
+}
+
+// rsGeneratorPoly builds the Reed-Solomon generator polynomial for nsym
+// error-correction codewords: product of (x - 2^i) for i in [0, nsym).
+func rsGeneratorPoly(nsym int) []byte {
+	g := []byte{1}
+	for i := 0; i < nsym; i++ {
+		g = gfPolyMul(g, []byte{1, gfExp[i]})
+	}
+	return g
+}
+
+// rsEncode appends nsym Reed-Solomon error-correction codewords to data.
+func rsEncode(data []byte, nsym int) []byte {
+	gen := rsGeneratorPoly(nsym)
+	remainder := make([]byte, len(data)+nsym)
+	copy(remainder, data)
+	for i := 0; i < len(data); i++ {
+		coef := remainder[i]
+		if coef == 0 {
+			continue
+		}
+		for j, gc := range gen {
+			remainder[i+j] ^= gfMul(gc, coef)
+		}
+	}
+	out := make([]byte, len(data)+nsym)
+	copy(out, data)
+	copy(out[len(data):], remainder[len(data):])
+	return out
+}
+
+// rsSyndromes computes the error syndromes for a received codeword; all
+// zero means no errors were detected.
+func rsSyndromes(codeword []byte, nsym int) []byte {
+	syn := make([]byte, nsym)
+	for i := 0; i < nsym; i++ {
+		// Evaluate the received polynomial at 2^i using Horner's method;
+		// a systematic codeword is a multiple of the generator, so every
+		// root of the generator is also a root here when there's no error.
+		var eval byte
+		for _, c := range codeword {
+			eval = gfMul(eval, gfExp[i]) ^ c
+		}
+		syn[i] = eval
+	}
+	return syn
+}
+
+// rsCorrect attempts to correct errors in codeword (data+ecc, nsym of
+// which are error-correction codewords) using Berlekamp-Massey and Forney.
+// It returns the corrected codeword, or an error if it found more errors
+// than nsym/2 can fix.
+func rsCorrect(codeword []byte, nsym int) ([]byte, error) {
+	syn := rsSyndromes(codeword, nsym)
+	allZero := true
+	for _, s := range syn {
+		if s != 0 {
+			allZero = false
+			break
+		}
+	}
+	if allZero {
+		return codeword, nil
+	}
+
+	errLoc := berlekampMassey(syn, nsym)
+	if len(errLoc)-1 > nsym/2 {
+		return nil, errQRDecodeUncorrectable
+	}
+
+	errPos := findErrorPositions(errLoc, len(codeword))
+	if errPos == nil || len(errPos) != len(errLoc)-1 {
+		return nil, errQRDecodeUncorrectable
+	}
+
+	corrected := append([]byte(nil), codeword...)
+	if err := forneyCorrect(corrected, syn, errLoc, errPos); err != nil {
+		return nil, err
+	}
+
+	if syn := rsSyndromes(corrected, nsym); !allZeroBytes(syn) {
+		return nil, errQRDecodeUncorrectable
+	}
+	return corrected, nil
+}
+
+func allZeroBytes(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// berlekampMassey finds the error locator polynomial for the given
+// syndromes.
+func berlekampMassey(syn []byte, nsym int) []byte {
+	errLoc := []byte{1}
+	oldLoc := []byte{1}
+
+	for i := 0; i < nsym; i++ {
+		oldLoc = append(oldLoc, 0)
+
+		// errLoc is stored highest-degree-first, so the constant term (its
+		// coefficient is implicitly 1) sits at the end; walk it from there
+		// to pair each coefficient with the right syndrome.
+		n := len(errLoc)
+		delta := syn[i]
+		for j := 1; j < n; j++ {
+			delta ^= gfMul(errLoc[n-1-j], syn[i-j])
+		}
+
+		if delta != 0 {
+			if len(oldLoc) > len(errLoc) {
+				newLoc := gfPolyScale(oldLoc, delta)
+				oldLoc = gfPolyScale(errLoc, gfInv(delta))
+				errLoc = newLoc
+			}
+			errLoc = gfPolyAdd(errLoc, gfPolyScale(oldLoc, delta))
+		}
+	}
+
+	for len(errLoc) > 0 && errLoc[0] == 0 {
+		errLoc = errLoc[1:]
+	}
+	return errLoc
+}
+
+func gfInv(a byte) byte {
+	return gfExp[255-int(gfLog[a])]
+}
+
+func gfPolyScale(p []byte, c byte) []byte {
+	out := make([]byte, len(p))
+	for i, v := range p {
+		out[i] = gfMul(v, c)
+	}
+	return out
+}
+
+func gfPolyAdd(a, b []byte) []byte {
+	n := len(a)
+	if len(b) > n {
+		n = len(b)
+	}
+	out := make([]byte, n)
+	for i := 0; i < len(a); i++ {
+		out[n-len(a)+i] ^= a[i]
+	}
+	for i := 0; i < len(b); i++ {
+		out[n-len(b)+i] ^= b[i]
+	}
+	return out
+}
+
+// findErrorPositions finds the roots of errLoc by brute-force (Chien
+// search), returning codeword indices (0 = most significant codeword).
+func findErrorPositions(errLoc []byte, codewordLen int) []int {
+	var positions []int
+	for i := 0; i < codewordLen; i++ {
+		x := gfExp[(255-i)%255]
+		var y byte
+		for _, c := range errLoc {
+			y = gfMul(y, x) ^ c
+		}
+		if y == 0 {
+			positions = append(positions, codewordLen-1-i)
+		}
+	}
+	return positions
+}
+
+// forneyCorrect applies the Forney algorithm to fix codeword in place at
+// the positions found by findErrorPositions.
+func forneyCorrect(codeword []byte, syn []byte, errLoc []byte, errPos []int) error {
+	synPoly := make([]byte, len(syn))
+	for i, v := range syn {
+		synPoly[len(syn)-1-i] = v
+	}
+
+	errEval := gfPolyMul(synPoly, errLoc)
+	if len(errEval) > len(syn) {
+		errEval = errEval[len(errEval)-len(syn):]
+	}
+
+	// Lambda'(x) only has the odd-power terms of Lambda(x), each with its
+	// exponent shifted down by one; since those exponents are all even,
+	// Lambda'(x) can be written as a polynomial in x^2. Collect its
+	// coefficients, then reverse so the Horner evaluation below (which
+	// walks highest power first, like every other polynomial in this
+	// file) sees them in the right order.
+	errLocDeriv := make([]byte, 0, len(errLoc)/2+1)
+	for i := len(errLoc) - 2; i >= 0; i -= 2 {
+		errLocDeriv = append(errLocDeriv, errLoc[i])
+	}
+	for l, r := 0, len(errLocDeriv)-1; l < r; l, r = l+1, r-1 {
+		errLocDeriv[l], errLocDeriv[r] = errLocDeriv[r], errLocDeriv[l]
+	}
+
+	for _, pos := range errPos {
+		// x is the root of errLoc found for this position, i.e. Xk^-1;
+		// Omega and Lambda' are evaluated there, and the Xk factor in the
+		// Forney formula itself is its inverse.
+		x := gfExp[(255-(len(codeword)-1-pos))%255]
+		xInv := gfInv(x)
+
+		var ey byte
+		for _, c := range errEval {
+			ey = gfMul(ey, x) ^ c
+		}
+
+		var deriv byte
+		for _, c := range errLocDeriv {
+			deriv = gfMul(deriv, gfMul(x, x)) ^ c
+		}
+		if deriv == 0 {
+			return errQRDecodeUncorrectable
+		}
+
+		magnitude := gfMul(xInv, gfMul(ey, gfInv(deriv)))
+		codeword[pos] ^= magnitude
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package qrtransport
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestRSCorrectRoundTrip encodes random payloads, corrupts up to nsym/2
+// bytes, and checks rsCorrect recovers the original codeword. This is the
+// property rsCorrect promises: it can fix any error pattern of weight at
+// most nsym/2.
+func TestRSCorrectRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for trial := 0; trial < 200; trial++ {
+		dataLen := 1 + rng.Intn(40)
+		nsym := 2 + rng.Intn(15)
+
+		data := make([]byte, dataLen)
+		rng.Read(data)
+
+		codeword := rsEncode(data, nsym)
+
+		corrupted := append([]byte(nil), codeword...)
+		maxErrs := nsym / 2
+		nErrs := rng.Intn(maxErrs + 1)
+		used := map[int]bool{}
+		for len(used) < nErrs {
+			pos := rng.Intn(len(corrupted))
+			if used[pos] {
+				continue
+			}
+			used[pos] = true
+			var bad byte
+			for bad == 0 || bad == corrupted[pos] {
+				bad = byte(rng.Intn(256))
+			}
+			corrupted[pos] = bad
+		}
+
+		corrected, err := rsCorrect(corrupted, nsym)
+		if err != nil {
+			t.Fatalf("trial %d: rsCorrect failed with %d errors (nsym=%d): %v", trial, nErrs, nsym, err)
+		}
+		for i := range codeword {
+			if corrected[i] != codeword[i] {
+				t.Fatalf("trial %d: corrected codeword mismatch at byte %d: got %#x want %#x", trial, i, corrected[i], codeword[i])
+			}
+		}
+	}
+}
+
+// TestRSCorrectNoErrors checks the fast path where the codeword is
+// already clean.
+func TestRSCorrectNoErrors(t *testing.T) {
+	data := []byte("hello, gotpi")
+	nsym := 10
+	codeword := rsEncode(data, nsym)
+
+	corrected, err := rsCorrect(codeword, nsym)
+	if err != nil {
+		t.Fatalf("rsCorrect on clean codeword failed: %v", err)
+	}
+	for i := range codeword {
+		if corrected[i] != codeword[i] {
+			t.Fatalf("unexpected change at byte %d: got %#x want %#x", i, corrected[i], codeword[i])
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package qrtransport
+
+// placeFunctionPatterns draws the finder patterns, separators, timing
+// patterns, alignment patterns, and the dark module, and reserves the
+// format-information strips (filled in later by placeFormatInfo) - all
+// marked isFunc so the data placement and masking passes skip them.
+func placeFunctionPatterns(m *Matrix) {
+	placeFinder(m, 0, 0)
+	placeFinder(m, m.Size-7, 0)
+	placeFinder(m, 0, m.Size-7)
+
+	for i := 0; i < m.Size; i++ {
+		dark := i%2 == 0
+		if !m.isFunc[6][i] {
+			m.set(i, 6, dark, true)
+		}
+		if !m.isFunc[i][6] {
+			m.set(6, i, dark, true)
+		}
+	}
+
+	for _, cx := range alignmentPositions[m.Version] {
+		for _, cy := range alignmentPositions[m.Version] {
+			if overlapsFinder(m, cx, cy) {
+				continue
+			}
+			placeAlignment(m, cx, cy)
+		}
+	}
+
+	m.set(8, m.Size-8, true, true) // dark module
+
+	reserveFormatInfo(m)
+}
+
+func overlapsFinder(m *Matrix, cx, cy int) bool {
+	corners := [][2]int{{3, 3}, {m.Size - 4, 3}, {3, m.Size - 4}}
+	for _, c := range corners {
+		if abs(cx-c[0]) <= 4 && abs(cy-c[1]) <= 4 {
+			return true
+		}
+	}
+	return false
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func placeFinder(m *Matrix, x0, y0 int) {
+	for dy := -1; dy <= 7; dy++ {
+		for dx := -1; dx <= 7; dx++ {
+			x, y := x0+dx, y0+dy
+			if x < 0 || y < 0 || x >= m.Size || y >= m.Size {
+				continue
+			}
+			ring := dx == -1 || dx == 7 || dy == -1 || dy == 7
+			inner := dx >= 2 && dx <= 4 && dy >= 2 && dy <= 4
+			dark := !ring && (inner || dx == 0 || dx == 6 || dy == 0 || dy == 6)
+			m.set(x, y, dark, true)
+		}
+	}
+}
+
+func placeAlignment(m *Matrix, cx, cy int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			ring := dx == -2 || dx == 2 || dy == -2 || dy == 2
+			dark := ring || (dx == 0 && dy == 0)
+			m.set(cx+dx, cy+dy, dark, true)
+		}
+	}
+}
+
+// reserveFormatInfo marks the two format-information strips as function
+// modules (content is written later, once the mask is chosen).
+func reserveFormatInfo(m *Matrix) {
+	for i := 0; i < 9; i++ {
+		m.isFunc[8][i] = true
+		m.isFunc[i][8] = true
+	}
+	for i := 0; i < 8; i++ {
+		m.isFunc[8][m.Size-1-i] = true
+		m.isFunc[m.Size-1-i][8] = true
+	}
+}
+
+// dataModulePositions enumerates every non-function module in the
+// zigzag, bottom-right-to-top-left, two-columns-at-a-time order the QR
+// spec places codeword bits in (skipping the vertical timing column).
+func dataModulePositions(m *Matrix) []pos {
+	var out []pos
+	up := true
+	for x := m.Size - 1; x > 0; x -= 2 {
+		if x == 6 {
+			x-- // the timing column itself carries no data
+		}
+		for i := 0; i < m.Size; i++ {
+			y := i
+			if up {
+				y = m.Size - 1 - i
+			}
+			for _, dx := range [2]int{0, -1} {
+				cx := x + dx
+				if !m.isFunc[y][cx] {
+					out = append(out, pos{cx, y})
+				}
+			}
+		}
+		up = !up
+	}
+	return out
+}
+
+type pos struct{ x, y int }
+
+// placeCodewords writes codewords' bits (MSB-first per byte) into
+// positions, in order.
+func placeCodewords(m *Matrix, codewords []byte, positions []pos) {
+	br := newBitReader(codewords)
+	for _, p := range positions {
+		bit := br.readBits(1)
+		m.set(p.x, p.y, bit != 0, false)
+	}
+}
+
+// maskFuncs holds the 8 standard QR data-masking predicates; a module is
+// flipped where the predicate is true.
+var maskFuncs = [8]func(x, y int) bool{
+	func(x, y int) bool { return (x+y)%2 == 0 },
+	func(x, y int) bool { return y%2 == 0 },
+	func(x, y int) bool { return x%3 == 0 },
+	func(x, y int) bool { return (x+y)%3 == 0 },
+	func(x, y int) bool { return (y/2+x/3)%2 == 0 },
+	func(x, y int) bool { return (x*y)%2+(x*y)%3 == 0 },
+	func(x, y int) bool { return ((x*y)%2+(x*y)%3)%2 == 0 },
+	func(x, y int) bool { return ((x+y)%2+(x*y)%3)%2 == 0 },
+}
+
+func applyMask(m *Matrix, mask int, positions []pos) {
+	f := maskFuncs[mask]
+	for _, p := range positions {
+		if f(p.x, p.y) {
+			m.modules[p.y][p.x] = !m.modules[p.y][p.x]
+		}
+	}
+}
+
+// chooseBestMask tries all 8 masks and returns the index with the lowest
+// penalty score, per the QR spec's four penalty rules.
+func chooseBestMask(m *Matrix, positions []pos) int {
+	best, bestScore := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		applyMask(m, mask, positions)
+		score := penaltyScore(m)
+		applyMask(m, mask, positions) // undo (masking twice is identity)
+
+		if bestScore == -1 || score < bestScore {
+			best, bestScore = mask, score
+		}
+	}
+	return best
+}
+
+func penaltyScore(m *Matrix) int {
+	score := 0
+	// Rule 1: runs of 5+ same-color modules in a row/column.
+	for y := 0; y < m.Size; y++ {
+		score += runPenalty(func(i int) bool { return m.modules[y][i] }, m.Size)
+	}
+	for x := 0; x < m.Size; x++ {
+		score += runPenalty(func(i int) bool { return m.modules[i][x] }, m.Size)
+	}
+	// Rule 2: 2x2 blocks of the same color.
+	for y := 0; y < m.Size-1; y++ {
+		for x := 0; x < m.Size-1; x++ {
+			c := m.modules[y][x]
+			if m.modules[y][x+1] == c && m.modules[y+1][x] == c && m.modules[y+1][x+1] == c {
+				score += 3
+			}
+		}
+	}
+	// Rule 3: the finder-like 1:1:3:1:1 pattern appearing in the data.
+	for y := 0; y < m.Size; y++ {
+		score += finderLikePenalty(func(i int) bool { return m.modules[y][i] }, m.Size)
+	}
+	for x := 0; x < m.Size; x++ {
+		score += finderLikePenalty(func(i int) bool { return m.modules[i][x] }, m.Size)
+	}
+	// Rule 4: overall dark/light balance.
+	dark := 0
+	for y := 0; y < m.Size; y++ {
+		for x := 0; x < m.Size; x++ {
+			if m.modules[y][x] {
+				dark++
+			}
+		}
+	}
+	percent := dark * 100 / (m.Size * m.Size)
+	diff := percent - 50
+	if diff < 0 {
+		diff = -diff
+	}
+	score += (diff / 5) * 10
+
+	return score
+}
+
+func runPenalty(at func(i int) bool, n int) int {
+	score, run := 0, 1
+	for i := 1; i < n; i++ {
+		if at(i) == at(i-1) {
+			run++
+			continue
+		}
+		if run >= 5 {
+			score += 3 + (run - 5)
+		}
+		run = 1
+	}
+	if run >= 5 {
+		score += 3 + (run - 5)
+	}
+	return score
+}
+
+func finderLikePenalty(at func(i int) bool, n int) int {
+	pattern := []bool{true, false, true, true, true, false, true}
+	score := 0
+	for i := 0; i+len(pattern) <= n; i++ {
+		match := true
+		for j, want := range pattern {
+			if at(i+j) != want {
+				match = false
+				break
+			}
+		}
+		if match {
+			score += 40
+		}
+	}
+	return score
+}
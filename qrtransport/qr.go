@@ -0,0 +1,228 @@
+// Package qrtransport implements a minimal, pure-Go QR Code encoder and
+// decoder (byte mode only) used by `gotpi key-export`/`key-import` to move
+// an OTP key image as a QR-tiled sheet image. The decoder locates tiles by
+// the exact pixel offsets recorded in the accompanying SheetManifest
+// rather than by searching for finder patterns, so this is a digital
+// transport of the sheet image, not one that tolerates a print-and-scan or
+// photograph round trip.
+//
+// Only single-RS-block version/ECC-level combinations are supported
+// (versions 1-5 at L, 1-3 at M, and 1-2 at Q/H) - the QR spec interleaves
+// multiple Reed-Solomon blocks starting at version 3 for higher ECC
+// levels, which this package doesn't implement yet. Callers should chunk
+// their payload to fit comfortably inside whichever of these is chosen;
+// see MaxDataBytes.
+package qrtransport
+
+import (
+	"errors"
+	"image"
+	"image/color"
+)
+
+// ECCLevel is a QR Code error-correction level.
+type ECCLevel int
+
+const (
+	ECCLow ECCLevel = iota
+	ECCMedium
+	ECCQuartile
+	ECCHigh
+)
+
+// String renders the level the way QR tooling conventionally does: L, M,
+// Q or H.
+func (l ECCLevel) String() string {
+	switch l {
+	case ECCLow:
+		return "L"
+	case ECCMedium:
+		return "M"
+	case ECCQuartile:
+		return "Q"
+	case ECCHigh:
+		return "H"
+	default:
+		return "?"
+	}
+}
+
+// ParseECCLevel parses the --ecc flag value (L, M, Q or H, case-insensitive).
+func ParseECCLevel(s string) (ECCLevel, error) {
+	switch s {
+	case "L", "l":
+		return ECCLow, nil
+	case "M", "m":
+		return ECCMedium, nil
+	case "Q", "q":
+		return ECCQuartile, nil
+	case "H", "h":
+		return ECCHigh, nil
+	default:
+		return 0, errQRUnsupportedECC
+	}
+}
+
+var (
+	errQRUnsupportedECC      = errors.New("qrtransport: unsupported ECC level")
+	errQRUnsupportedVersion  = errors.New("qrtransport: unsupported or multi-block version/ECC combination")
+	errQRDataTooLong         = errors.New("qrtransport: data too long for the chosen version/ECC level")
+	errQRDecodeUncorrectable = errors.New("qrtransport: too many errors to correct")
+	errQRFormatInfo          = errors.New("qrtransport: could not read format information")
+)
+
+// capacity describes a single-RS-block version/ECC-level combination.
+type capacity struct {
+	dataCodewords int
+	ecCodewords   int
+}
+
+// capacities holds every version/ECC-level pair this package supports -
+// exactly the ones where the QR spec uses a single Reed-Solomon block.
+var capacities = map[int]map[ECCLevel]capacity{
+	1: {ECCLow: {19, 7}, ECCMedium: {16, 10}, ECCQuartile: {13, 13}, ECCHigh: {9, 17}},
+	2: {ECCLow: {34, 10}, ECCMedium: {28, 16}, ECCQuartile: {22, 22}, ECCHigh: {16, 28}},
+	3: {ECCLow: {55, 15}, ECCMedium: {44, 26}},
+	4: {ECCLow: {80, 20}},
+	5: {ECCLow: {108, 26}},
+}
+
+// alignmentPositions gives the center coordinates of alignment-pattern
+// modules for versions 2-5 (version 1 has none).
+var alignmentPositions = map[int][]int{
+	2: {6, 18},
+	3: {6, 22},
+	4: {6, 26},
+	5: {6, 30},
+}
+
+// MaxDataBytes returns the byte-mode data capacity for version at ecc, or
+// an error if that combination isn't a supported single-block one.
+func MaxDataBytes(version int, ecc ECCLevel) (int, error) {
+	cap, ok := capacities[version][ecc]
+	if !ok {
+		return 0, errQRUnsupportedVersion
+	}
+	// Mode indicator + count indicator take 2 bytes worth of bits (4+8),
+	// rounded up; the terminator and pad fit inside what's left.
+	usable := cap.dataCodewords - 2
+	if usable < 0 {
+		usable = 0
+	}
+	return usable, nil
+}
+
+// Size returns the module width/height of a QR symbol at version (not
+// including the quiet zone).
+func Size(version int) int {
+	return 17 + 4*version
+}
+
+// Matrix is a rendered QR Code's module grid: true = dark module.
+type Matrix struct {
+	Version int
+	ECC     ECCLevel
+	Size    int
+	modules [][]bool
+	isFunc  [][]bool
+}
+
+func newMatrix(version int, ecc ECCLevel) *Matrix {
+	size := Size(version)
+	m := &Matrix{Version: version, ECC: ecc, Size: size}
+	m.modules = make([][]bool, size)
+	m.isFunc = make([][]bool, size)
+	for i := range m.modules {
+		m.modules[i] = make([]bool, size)
+		m.isFunc[i] = make([]bool, size)
+	}
+	return m
+}
+
+func (m *Matrix) set(x, y int, dark, isFunc bool) {
+	m.modules[y][x] = dark
+	m.isFunc[y][x] = isFunc
+}
+
+// Encode builds a QR Code for data at the given version and ECC level.
+// data must fit within MaxDataBytes(version, ecc).
+func Encode(data []byte, version int, ecc ECCLevel) (*Matrix, error) {
+	cap, ok := capacities[version][ecc]
+	if !ok {
+		return nil, errQRUnsupportedVersion
+	}
+
+	bits, err := encodeBitStream(data, cap.dataCodewords)
+	if err != nil {
+		return nil, err
+	}
+	codewords := rsEncode(bits, cap.ecCodewords)
+
+	m := newMatrix(version, ecc)
+	placeFunctionPatterns(m)
+	dataPositions := dataModulePositions(m)
+	placeCodewords(m, codewords, dataPositions)
+
+	mask := chooseBestMask(m, dataPositions)
+	applyMask(m, mask, dataPositions)
+	placeFormatInfo(m, ecc, mask)
+
+	return m, nil
+}
+
+// encodeBitStream builds the byte-mode bit stream: mode indicator, 8-bit
+// count indicator, the data itself, a terminator, and 0xEC/0x11 padding up
+// to dataCodewords bytes.
+func encodeBitStream(data []byte, dataCodewords int) ([]byte, error) {
+	if len(data) > dataCodewords-2 {
+		return nil, errQRDataTooLong
+	}
+
+	bw := newBitWriter()
+	bw.writeBits(0b0100, 4) // byte mode
+	bw.writeBits(uint32(len(data)), 8)
+	for _, b := range data {
+		bw.writeBits(uint32(b), 8)
+	}
+
+	totalBits := dataCodewords * 8
+	if remaining := totalBits - bw.len(); remaining > 0 {
+		term := 4
+		if remaining < term {
+			term = remaining
+		}
+		bw.writeBits(0, term)
+	}
+	bw.padToByte()
+
+	pad := [2]byte{0xEC, 0x11}
+	for i := 0; bw.len()/8 < dataCodewords; i++ {
+		bw.writeBits(uint32(pad[i%2]), 8)
+	}
+	return bw.bytes(), nil
+}
+
+// Render draws m as an image, scale pixels per module, surrounded by a
+// quietZone-module border of light modules on every side.
+func Render(m *Matrix, scale, quietZone int) image.Image {
+	side := (m.Size + 2*quietZone) * scale
+	img := image.NewGray(image.Rect(0, 0, side, side))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	for y := 0; y < m.Size; y++ {
+		for x := 0; x < m.Size; x++ {
+			if !m.modules[y][x] {
+				continue
+			}
+			px0 := (x + quietZone) * scale
+			py0 := (y + quietZone) * scale
+			for py := py0; py < py0+scale; py++ {
+				for px := px0; px < px0+scale; px++ {
+					img.SetGray(px, py, color.Gray{Y: 0})
+				}
+			}
+		}
+	}
+	return img
+}
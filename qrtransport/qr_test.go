@@ -0,0 +1,44 @@
+package qrtransport
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	for _, ecc := range []ECCLevel{ECCLow, ECCMedium, ECCQuartile, ECCHigh} {
+		for version := 1; version <= maxVersionFor(ecc); version++ {
+			// "gotpi" (5 bytes) fits every supported version/ECC
+			// combination; the smallest, version 1 at ECCHigh, only
+			// carries 7.
+			payload := []byte("gotpi")
+
+			m, err := Encode(payload, version, ecc)
+			if err != nil {
+				t.Fatalf("Encode(version=%d, ecc=%s): %v", version, ecc, err)
+			}
+			img := Render(m, 4, 2)
+
+			got, err := Decode(img, version, 4, 2)
+			if err != nil {
+				t.Fatalf("Decode(version=%d, ecc=%s): %v", version, ecc, err)
+			}
+			if !bytes.Equal(got, payload) {
+				t.Fatalf("version=%d ecc=%s: got %q, want %q", version, ecc, got, payload)
+			}
+		}
+	}
+}
+
+// maxVersionFor mirrors the single-RS-block versions qrtransport supports
+// per ECC level (see the package doc comment).
+func maxVersionFor(ecc ECCLevel) int {
+	switch ecc {
+	case ECCLow:
+		return 5
+	case ECCMedium:
+		return 3
+	default:
+		return 2
+	}
+}
@@ -0,0 +1,154 @@
+package gotpi
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// DefaultTileSize is the tile edge length (in pixels) EncryptStream uses
+// when StreamOpts.TileSize is zero. It also sets the height of the row
+// band read from src/key and written to dst at a time.
+const DefaultTileSize = 256
+
+// StreamOpts configures EncryptStream.
+type StreamOpts struct {
+	// TileSize is the edge length of the square tiles src is processed
+	// in, and the number of rows read/written per band. Zero uses
+	// DefaultTileSize.
+	TileSize int
+	// Workers caps how many tiles are XORed concurrently. Zero uses
+	// runtime.GOMAXPROCS(0).
+	Workers int
+}
+
+// ErrKeyTooSmall is returned by EncryptStream when key is smaller than
+// src in either dimension.
+var ErrKeyTooSmall = errors.New("gotpi: key image is smaller than the source image")
+
+// EncryptStream encrypts src against key tile-by-tile - the same RGB XOR
+// Encrypt/encRGB perform - and writes the result to dst as PNG.
+//
+// Unlike Encrypt, EncryptStream never holds a full-image buffer: src and
+// key are decoded, and dst is encoded, one row band of opts.TileSize rows
+// at a time via pngStreamReader/pngStreamWriter, so peak memory is
+// proportional to image width * tile height, not image width * height.
+// Within a band, tiles are XORed concurrently across opts.Workers
+// goroutines, each summing directly off packed RGBA bytes via
+// subtle.XORBytes instead of a per-pixel RGBA()/Set() conversion.
+//
+// That streaming decoder only understands 8-bit, non-interlaced PNG
+// (grayscale, RGB or RGBA) - the shape every gotpi-produced key/image PNG
+// has. A src or key that is not a PNG at all is rejected as not a PNG
+// stream, and one in any other PNG shape (paletted, 16-bit, interlaced)
+// returns an error wrapping ErrUnsupportedStreamPNG; encrypt those with
+// Encrypt instead, which decodes through the standard image package and
+// so accepts anything it supports.
+//
+// EncryptStream does not resize src to key's dimensions the way Encrypt
+// does: resizing needs the whole image decoded anyway, which defeats the
+// point of streaming, so the caller must supply a key whose bounds are at
+// least as large as src's.
+func EncryptStream(src, key io.Reader, dst io.Writer, opts StreamOpts) error {
+	tileSize := opts.TileSize
+	if tileSize <= 0 {
+		tileSize = DefaultTileSize
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	srcDec, err := newPNGStreamReader(src)
+	if err != nil {
+		return fmt.Errorf("gotpi: opening source image for streaming: %w", err)
+	}
+	keyDec, err := newPNGStreamReader(key)
+	if err != nil {
+		return fmt.Errorf("gotpi: opening key image for streaming: %w", err)
+	}
+	if keyDec.Width < srcDec.Width || keyDec.Height < srcDec.Height {
+		return ErrKeyTooSmall
+	}
+
+	enc, err := newPNGStreamWriter(dst, srcDec.Width, srcDec.Height, tileSize)
+	if err != nil {
+		return fmt.Errorf("gotpi: opening destination image for streaming: %w", err)
+	}
+
+	for y := 0; y < srcDec.Height; y += tileSize {
+		bandHeight := min(tileSize, srcDec.Height-y)
+
+		srcBand, err := readRowBand(srcDec, bandHeight)
+		if err != nil {
+			return fmt.Errorf("gotpi: decoding source row band at y=%d: %w", y, err)
+		}
+		keyBand, err := readRowBand(keyDec, bandHeight)
+		if err != nil {
+			return fmt.Errorf("gotpi: decoding key row band at y=%d: %w", y, err)
+		}
+
+		outBand := xorBand(srcBand, keyBand, srcDec.Width, bandHeight, tileSize, workers)
+		for _, row := range outBand {
+			if err := enc.WriteRowRGBA(row); err != nil {
+				return fmt.Errorf("gotpi: writing row band at y=%d: %w", y, err)
+			}
+		}
+	}
+
+	return enc.Close()
+}
+
+// readRowBand reads n rows (already expanded to RGBA) from d.
+func readRowBand(d *pngStreamReader, n int) ([][]byte, error) {
+	band := make([][]byte, n)
+	for i := range band {
+		row, err := d.ReadRowRGBA()
+		if err != nil {
+			return nil, err
+		}
+		band[i] = row
+	}
+	return band, nil
+}
+
+// xorBand XORs a row band of src against key, column-tile by column-tile,
+// split across workers goroutines; tileWidth is also used as the column
+// tile width so each unit of work is roughly tileWidth x len(src) pixels.
+func xorBand(src, key [][]byte, width, height, tileWidth, workers int) [][]byte {
+	out := make([][]byte, height)
+	for i := range out {
+		out[i] = make([]byte, width*4)
+	}
+
+	type tile struct{ x0, x1 int }
+	tiles := make(chan tile)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range tiles {
+				for y := 0; y < height; y++ {
+					outRow := out[y][t.x0*4 : t.x1*4]
+					srcRow := src[y][t.x0*4 : t.x1*4]
+					keyRow := key[y][t.x0*4 : t.x1*4]
+					subtle.XORBytes(outRow, srcRow, keyRow)
+					for i := 3; i < len(outRow); i += 4 {
+						outRow[i] = 0xff
+					}
+				}
+			}
+		}()
+	}
+	for x0 := 0; x0 < width; x0 += tileWidth {
+		tiles <- tile{x0, min(x0+tileWidth, width)}
+	}
+	close(tiles)
+	wg.Wait()
+
+	return out
+}
@@ -0,0 +1,54 @@
+package gotpi
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncryptStreamMatchesEncrypt(t *testing.T) {
+	img := KeyGen(37, true) // odd width/height so bands don't divide evenly
+	key := KeyGen(37, true)
+
+	var imgPNG, keyPNG bytes.Buffer
+	if err := Save(&imgPNG, img, FormatPNG, nil); err != nil {
+		t.Fatalf("Save img: %v", err)
+	}
+	if err := Save(&keyPNG, key, FormatPNG, nil); err != nil {
+		t.Fatalf("Save key: %v", err)
+	}
+
+	var streamed bytes.Buffer
+	opts := StreamOpts{TileSize: 16, Workers: 2}
+	if err := EncryptStream(bytes.NewReader(imgPNG.Bytes()), bytes.NewReader(keyPNG.Bytes()), &streamed, opts); err != nil {
+		t.Fatalf("EncryptStream: %v", err)
+	}
+
+	got, _, err := Load(&streamed)
+	if err != nil {
+		t.Fatalf("Load streamed output: %v", err)
+	}
+
+	want := Encrypt(img, key, true)
+	if !bytes.Equal(rgbaPixels(got), rgbaPixels(want)) {
+		t.Fatalf("EncryptStream output doesn't match Encrypt")
+	}
+}
+
+func TestEncryptStreamKeyTooSmall(t *testing.T) {
+	img := KeyGen(32, true)
+	key := KeyGen(16, true)
+
+	var imgPNG, keyPNG bytes.Buffer
+	if err := Save(&imgPNG, img, FormatPNG, nil); err != nil {
+		t.Fatalf("Save img: %v", err)
+	}
+	if err := Save(&keyPNG, key, FormatPNG, nil); err != nil {
+		t.Fatalf("Save key: %v", err)
+	}
+
+	var out bytes.Buffer
+	err := EncryptStream(bytes.NewReader(imgPNG.Bytes()), bytes.NewReader(keyPNG.Bytes()), &out, StreamOpts{})
+	if err != ErrKeyTooSmall {
+		t.Fatalf("got err %v, want ErrKeyTooSmall", err)
+	}
+}
@@ -0,0 +1,55 @@
+// Package transform implements the small set of lossless geometric
+// operations - flips and 90-degree rotations - needed to normalize an
+// image's EXIF orientation before encryption and restore it afterward.
+package transform
+
+import "image"
+
+// FlipH mirrors img left-to-right.
+func FlipH(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	out := image.NewRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			mx := b.Min.X + (b.Max.X - 1 - x)
+			out.Set(mx, y, img.At(x, y))
+		}
+	}
+	return out
+}
+
+// FlipV mirrors img top-to-bottom.
+func FlipV(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	out := image.NewRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		my := b.Min.Y + (b.Max.Y - 1 - y)
+		for x := b.Min.X; x < b.Max.X; x++ {
+			out.Set(x, my, img.At(x, y))
+		}
+	}
+	return out
+}
+
+// Rotate90 rotates img 90 degrees clockwise.
+func Rotate90(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	out := image.NewRGBA(image.Rect(0, 0, h, w))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			out.Set(h-1-y, x, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return out
+}
+
+// Rotate180 rotates img 180 degrees.
+func Rotate180(img image.Image) *image.RGBA {
+	return FlipV(FlipH(img))
+}
+
+// Rotate270 rotates img 270 degrees clockwise (90 degrees counterclockwise).
+func Rotate270(img image.Image) *image.RGBA {
+	return Rotate90(Rotate180(img))
+}
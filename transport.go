@@ -0,0 +1,353 @@
+package gotpi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/micheledinelli/gotpi/qrtransport"
+)
+
+// chunkHeaderLen is the fixed size of the "GK|seq|total|crc32|" header
+// gotpi prefixes to every QR tile's payload, zero-padded so key-import can
+// split header from payload without scanning for delimiters of unknown
+// width.
+const chunkHeaderLen = 24 // "GK|" + 5 + "|" + 5 + "|" + 8 + "|"
+
+var (
+	// ErrSheetTooSmall is returned by ExportKeySheet when the chosen
+	// version/ECC level leaves no room for payload once the chunk header
+	// is accounted for.
+	ErrSheetTooSmall = errors.New("gotpi: QR version/ECC level too small to carry any payload")
+	errChunkHeader   = errors.New("gotpi: malformed QR chunk header")
+	errChunkCRC      = errors.New("gotpi: QR chunk failed CRC32 check")
+)
+
+// SheetManifest records how ExportKeySheet laid a key sheet out, so
+// ImportKeySheet can slice the tiles back apart by exact pixel offset
+// instead of locating finder patterns itself. It travels alongside the
+// sheet PNG as a handful of gotpi-qr-* tEXt chunks (see cmd/gotpi).
+//
+// This makes key-export/key-import a digital transport: the sheet's
+// pixels must reach ImportKeySheet unchanged apart from a uniform resize
+// (see ImportKeySheet), and its tEXt chunks must survive too. There is no
+// finder-pattern search, perspective correction, crop or rotation
+// recovery, so a lossy re-encode, print-and-scan, or photograph of the
+// sheet will not decode even though it "looks like" a QR code.
+type SheetManifest struct {
+	Version   int
+	ECC       qrtransport.ECCLevel
+	Scale     int
+	QuietZone int
+	Cols      int
+	Rows      int
+	Tiles     int
+	TileSize  int
+}
+
+// Fields returns m as the tEXt key/value pairs the CLI embeds in the sheet.
+func (m SheetManifest) Fields() map[string]string {
+	return map[string]string{
+		"gotpi-qr-version":   strconv.Itoa(m.Version),
+		"gotpi-qr-ecc":       m.ECC.String(),
+		"gotpi-qr-scale":     strconv.Itoa(m.Scale),
+		"gotpi-qr-quietzone": strconv.Itoa(m.QuietZone),
+		"gotpi-qr-cols":      strconv.Itoa(m.Cols),
+		"gotpi-qr-rows":      strconv.Itoa(m.Rows),
+		"gotpi-qr-tiles":     strconv.Itoa(m.Tiles),
+		"gotpi-qr-tilesize":  strconv.Itoa(m.TileSize),
+	}
+}
+
+// ManifestFromFields parses the tEXt key/value pairs Fields produced.
+func ManifestFromFields(get func(key string) (string, bool)) (SheetManifest, error) {
+	var m SheetManifest
+	ints := map[string]*int{
+		"gotpi-qr-version":   &m.Version,
+		"gotpi-qr-scale":     &m.Scale,
+		"gotpi-qr-quietzone": &m.QuietZone,
+		"gotpi-qr-cols":      &m.Cols,
+		"gotpi-qr-rows":      &m.Rows,
+		"gotpi-qr-tiles":     &m.Tiles,
+		"gotpi-qr-tilesize":  &m.TileSize,
+	}
+	for key, dst := range ints {
+		v, ok := get(key)
+		if !ok {
+			return m, fmt.Errorf("gotpi: sheet is missing %s metadata", key)
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return m, fmt.Errorf("gotpi: sheet has malformed %s metadata: %w", key, err)
+		}
+		*dst = n
+	}
+	eccStr, ok := get("gotpi-qr-ecc")
+	if !ok {
+		return m, errors.New("gotpi: sheet is missing gotpi-qr-ecc metadata")
+	}
+	ecc, err := qrtransport.ParseECCLevel(eccStr)
+	if err != nil {
+		return m, err
+	}
+	m.ECC = ecc
+	return m, nil
+}
+
+// ExportKeySheet gzip-compresses keyImg's raw pixels, splits the result
+// into chunks that fit one QR tile each (headered GK|seq|total|crc32 so
+// ImportKeySheet can reassemble and validate them), and tiles the tiles
+// into a single grid image alongside the SheetManifest describing that
+// layout.
+func ExportKeySheet(keyImg image.Image, version int, ecc qrtransport.ECCLevel) (image.Image, SheetManifest, error) {
+	payload, err := gzipKeyImage(keyImg)
+	if err != nil {
+		return nil, SheetManifest{}, err
+	}
+
+	maxData, err := qrtransport.MaxDataBytes(version, ecc)
+	if err != nil {
+		return nil, SheetManifest{}, err
+	}
+	chunkSize := maxData - chunkHeaderLen
+	if chunkSize <= 0 {
+		return nil, SheetManifest{}, ErrSheetTooSmall
+	}
+
+	total := (len(payload) + chunkSize - 1) / chunkSize
+	if total == 0 {
+		total = 1
+	}
+
+	var tiles []image.Image
+	var tileSize int
+	for seq := 0; seq < total; seq++ {
+		start := seq * chunkSize
+		end := start + chunkSize
+		if end > len(payload) {
+			end = len(payload)
+		}
+		chunk := payload[start:end]
+
+		header := fmt.Sprintf("GK|%05d|%05d|%08x|", seq, total, crc32.ChecksumIEEE(chunk))
+		full := append([]byte(header), chunk...)
+
+		m, err := qrtransport.Encode(full, version, ecc)
+		if err != nil {
+			return nil, SheetManifest{}, err
+		}
+		tile := qrtransport.Render(m, qrScale, qrQuietZone)
+		tileSize = tile.Bounds().Dx()
+		tiles = append(tiles, tile)
+	}
+
+	cols := int(math.Ceil(math.Sqrt(float64(total))))
+	rows := (total + cols - 1) / cols
+	sheet := tileGrid(tiles, cols, rows, tileSize)
+
+	manifest := SheetManifest{
+		Version:   version,
+		ECC:       ecc,
+		Scale:     qrScale,
+		QuietZone: qrQuietZone,
+		Cols:      cols,
+		Rows:      rows,
+		Tiles:     total,
+		TileSize:  tileSize,
+	}
+	return sheet, manifest, nil
+}
+
+// ImportKeySheet reverses ExportKeySheet: it slices sheet into tiles per
+// manifest, decodes and validates each one, reassembles the payload in
+// sequence order, and ungzips it back into the original key image.
+//
+// sheet and manifest must come from the same ExportKeySheet call (see the
+// SheetManifest doc comment). If sheet's dimensions don't match what
+// manifest's Cols/Rows/TileSize describe, it's resized (nearest-neighbor,
+// to preserve the modules' hard edges) back to that grid first, so a
+// sheet that was losslessly re-saved at a different resolution, e.g. by an
+// image editor, still decodes. This does not extend to a crop, rotation,
+// print-and-scan, or the perspective distortion of a photograph: tiles are
+// located only by the manifest's pixel offsets, with no finder-pattern
+// search (see the qrtransport package doc comment).
+func ImportKeySheet(sheet image.Image, manifest SheetManifest) (image.Image, error) {
+	wantW, wantH := manifest.Cols*manifest.TileSize, manifest.Rows*manifest.TileSize
+	if b := sheet.Bounds(); b.Dx() != wantW || b.Dy() != wantH {
+		sheet = resizeNearest(sheet, wantW, wantH)
+	}
+
+	chunks := make([][]byte, manifest.Tiles)
+	found := 0
+
+	for row := 0; row < manifest.Rows; row++ {
+		for col := 0; col < manifest.Cols; col++ {
+			idx := row*manifest.Cols + col
+			if idx >= manifest.Tiles {
+				continue
+			}
+			tile := subImage(sheet, col*manifest.TileSize, row*manifest.TileSize, manifest.TileSize, manifest.TileSize)
+
+			full, err := qrtransport.Decode(tile, manifest.Version, manifest.Scale, manifest.QuietZone)
+			if err != nil {
+				return nil, fmt.Errorf("gotpi: decoding QR tile %d: %w", idx, err)
+			}
+			seq, total, chunk, err := parseChunk(full)
+			if err != nil {
+				return nil, err
+			}
+			if total != manifest.Tiles {
+				return nil, fmt.Errorf("gotpi: tile %d reports %d total tiles, sheet manifest says %d", idx, total, manifest.Tiles)
+			}
+			if chunks[seq] == nil {
+				found++
+			}
+			chunks[seq] = chunk
+		}
+	}
+	if found != manifest.Tiles {
+		return nil, fmt.Errorf("gotpi: only recovered %d of %d tiles", found, manifest.Tiles)
+	}
+
+	var payload bytes.Buffer
+	for _, c := range chunks {
+		payload.Write(c)
+	}
+
+	return ungzipKeyImage(payload.Bytes())
+}
+
+// parseChunk splits a decoded QR payload into its GK|seq|total|crc32
+// header fields and validates the payload's CRC32 against the header.
+func parseChunk(full []byte) (seq, total int, payload []byte, err error) {
+	if len(full) < chunkHeaderLen {
+		return 0, 0, nil, errChunkHeader
+	}
+	header, rest := full[:chunkHeaderLen], full[chunkHeaderLen:]
+	fields := strings.Split(strings.TrimSuffix(string(header), "|"), "|")
+	if len(fields) != 4 || fields[0] != "GK" {
+		return 0, 0, nil, errChunkHeader
+	}
+	seq, err = strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, 0, nil, errChunkHeader
+	}
+	total, err = strconv.Atoi(fields[2])
+	if err != nil {
+		return 0, 0, nil, errChunkHeader
+	}
+	var wantCRC uint32
+	if _, err := fmt.Sscanf(fields[3], "%08x", &wantCRC); err != nil {
+		return 0, 0, nil, errChunkHeader
+	}
+	if crc32.ChecksumIEEE(rest) != wantCRC {
+		return 0, 0, nil, errChunkCRC
+	}
+	return seq, total, rest, nil
+}
+
+// gzipKeyImage serializes a key image as width, height (4 bytes each, big
+// endian) followed by its raw RGBA pixels, then gzips the result.
+func gzipKeyImage(img image.Image) ([]byte, error) {
+	b := img.Bounds()
+	var raw bytes.Buffer
+	binary.Write(&raw, binary.BigEndian, uint32(b.Dx()))
+	binary.Write(&raw, binary.BigEndian, uint32(b.Dy()))
+	raw.Write(rgbaPixels(img))
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	if _, err := w.Write(raw.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return gz.Bytes(), nil
+}
+
+// ungzipKeyImage reverses gzipKeyImage.
+func ungzipKeyImage(data []byte) (image.Image, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < 8 {
+		return nil, errors.New("gotpi: truncated key image payload")
+	}
+	w := binary.BigEndian.Uint32(raw[0:4])
+	h := binary.BigEndian.Uint32(raw[4:8])
+	pix := raw[8:]
+	if uint32(len(pix)) != w*h*4 {
+		return nil, errors.New("gotpi: key image payload size doesn't match its header")
+	}
+
+	out := image.NewRGBA(image.Rect(0, 0, int(w), int(h)))
+	copy(out.Pix, pix)
+	return out, nil
+}
+
+// resizeNearest scales img to the given width and height using
+// nearest-neighbor sampling. Unlike a smoothing resize, this keeps the
+// hard black/white module edges ImportKeySheet/qrtransport.Decode rely on
+// instead of blurring them.
+func resizeNearest(img image.Image, w, h int) image.Image {
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		sy := b.Min.Y + y*b.Dy()/h
+		for x := 0; x < w; x++ {
+			sx := b.Min.X + x*b.Dx()/w
+			out.Set(x, y, img.At(sx, sy))
+		}
+	}
+	return out
+}
+
+// subImage returns the x,y,w,h region of img as a standalone *image.RGBA.
+func subImage(img image.Image, x, y, w, h int) image.Image {
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	for dy := 0; dy < h; dy++ {
+		for dx := 0; dx < w; dx++ {
+			out.Set(dx, dy, img.At(img.Bounds().Min.X+x+dx, img.Bounds().Min.Y+y+dy))
+		}
+	}
+	return out
+}
+
+// tileGrid lays tiles out row-major into a cols x rows grid of tileSize
+// square cells, abutting (each tile already carries its own quiet zone).
+func tileGrid(tiles []image.Image, cols, rows, tileSize int) image.Image {
+	out := image.NewRGBA(image.Rect(0, 0, cols*tileSize, rows*tileSize))
+	for i := range out.Pix {
+		out.Pix[i] = 0xff
+	}
+	for i, tile := range tiles {
+		x0 := (i % cols) * tileSize
+		y0 := (i / cols) * tileSize
+		b := tile.Bounds()
+		for y := 0; y < b.Dy(); y++ {
+			for x := 0; x < b.Dx(); x++ {
+				out.Set(x0+x, y0+y, tile.At(b.Min.X+x, b.Min.Y+y))
+			}
+		}
+	}
+	return out
+}
+
+const (
+	qrScale     = 6
+	qrQuietZone = 4
+)
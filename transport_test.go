@@ -0,0 +1,49 @@
+package gotpi
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/micheledinelli/gotpi/qrtransport"
+)
+
+func TestKeySheetRoundTrip(t *testing.T) {
+	key := KeyGen(8, true)
+
+	sheet, manifest, err := ExportKeySheet(key, 2, qrtransport.ECCMedium)
+	if err != nil {
+		t.Fatalf("ExportKeySheet: %v", err)
+	}
+
+	got, err := ImportKeySheet(sheet, manifest)
+	if err != nil {
+		t.Fatalf("ImportKeySheet: %v", err)
+	}
+	if !bytes.Equal(rgbaPixels(got), rgbaPixels(key)) {
+		t.Fatalf("round-tripped key doesn't match original")
+	}
+}
+
+// TestKeySheetRoundTripAfterResize checks that a sheet re-saved at a
+// different resolution (simulating, e.g., a lossless re-export through an
+// image editor) still decodes, even though the exact pixel grid
+// ExportKeySheet produced no longer matches.
+func TestKeySheetRoundTripAfterResize(t *testing.T) {
+	key := KeyGen(8, true)
+
+	sheet, manifest, err := ExportKeySheet(key, 2, qrtransport.ECCMedium)
+	if err != nil {
+		t.Fatalf("ExportKeySheet: %v", err)
+	}
+
+	b := sheet.Bounds()
+	resized := resizeNearest(sheet, b.Dx()*3/2, b.Dy()*3/2)
+
+	got, err := ImportKeySheet(resized, manifest)
+	if err != nil {
+		t.Fatalf("ImportKeySheet after resize: %v", err)
+	}
+	if !bytes.Equal(rgbaPixels(got), rgbaPixels(key)) {
+		t.Fatalf("round-tripped key doesn't match original after resize")
+	}
+}